@@ -1,138 +1,485 @@
 package binding
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
-	"github.com/HailoOSS/binding-service/domain"
-	"io/ioutil"
-	"net/http"
-	"net/http/httptest"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/rabbit"
+	"github.com/HailoOSS/binding-service/util"
+	"github.com/HailoOSS/platform/raven"
 )
 
-func TestHttpErrorCheck(t *testing.T) {
-	resp := http.Response{}
-	resp.StatusCode = 200
-	err := checkError(&resp, nil, "Test error")
-	if err != nil {
-		t.Error("Error should be nil")
+func TestRebindBackoff(t *testing.T) {
+	defer func() { status.overruns = 0 }()
+	if d := rebindBackoff(); d != *rebindInterval {
+		t.Error("Backoff should be the rebind interval without overruns ", d)
 	}
-	resp.StatusCode = 201
-	err = checkError(&resp, nil, "Test error")
-	if err != nil {
-		t.Error("Error should be nil")
+	status.overruns = 1
+	if d := rebindBackoff(); d != 2**rebindInterval {
+		t.Error("Backoff should double after an overrun ", d)
 	}
-	resp.StatusCode = 400
-	err = checkError(&resp, nil, "Test error")
-	if err == nil {
-		t.Error("Error should not be nil")
+	status.overruns = 10
+	if d := rebindBackoff(); d != *rebindMaxBackoff {
+		t.Error("Backoff should be capped ", d)
 	}
-	resp.StatusCode = 200
-	err = checkError(&resp, errors.New("Some err"), "Test error")
-	if err == nil {
-		t.Error("Error should not be nil")
+}
+
+func TestRunningNodeHosts(t *testing.T) {
+	hosts, err := runningNodeHosts([]*rabbit.Node{
+		{Name: "rabbit@rabbit1", Running: true},
+		{Name: "rabbit@rabbit2", Running: false},
+		{Name: "rabbit@rabbit3", Running: true},
+	})
+	if err != nil || len(hosts) != 2 || hosts[0] != "rabbit1" || hosts[1] != "rabbit3" {
+		t.Error("Running hosts incorrect ", hosts, err)
 	}
-	resp.StatusCode = 500
-	err = checkError(&resp, nil, "Test error")
-	if err == nil {
-		t.Error("Error should not be nil")
+	if _, err := runningNodeHosts([]*rabbit.Node{{Name: "rabbit@rabbit1"}}); err == nil {
+		t.Error("Should be an error when no nodes are running")
+	}
+	if _, err := runningNodeHosts([]*rabbit.Node{{Name: "rabbit1", Running: true}}); err == nil {
+		t.Error("Should be an error for an unexpected node name")
+	}
+}
+
+func TestCompareBindings(t *testing.T) {
+	s := &domain.Service{Service: "com.HailoOSS.foo", Instance: "foo-1", AzName: "eu-west-1a", Vhost: "/"}
+	weighted := domain.BindingDefFromService(s)
+	weighted.Arguments["x-weight"] = float64(5)
+	desired := []*desiredBinding{
+		{kind: DRIFT_LOCAL, service: s.Service, def: weighted},
+		{kind: DRIFT_TOPIC, service: s.Service, def: topicBindingDef("/", s.Instance, "some.topic")},
+		{kind: DRIFT_REMOTE, service: s.Service, def: domain.ExchangeBindingDefFromService(s, "eu-west-1b")},
+	}
+	unweighted := domain.BindingDefFromService(s)
+	topic := topicBindingDef("/", s.Instance, "some.topic")
+	topic.Arguments = map[string]interface{}{}
+	stale := topicBindingDef("/", s.Instance, "old.topic")
+	actual := []*domain.BindingDef{
+		unweighted,
+		topic,
+		stale,
+		{Source: "amq.direct", Vhost: "/", Destination: "foo-1", DestinationType: "queue"}, // not ours
 	}
 
+	res := compareBindings("eu-west-1a", desired, actual, map[string]string{"foo-1": s.Service})
+	if len(res) != 3 {
+		t.Fatal("Should be three differences ", res)
+	}
+	if res[0].Problem != DRIFT_MISMATCHED || res[0].Kind != DRIFT_LOCAL || res[0].Actual != unweighted {
+		t.Error("Weight difference should be mismatched ", res[0])
+	}
+	if res[1].Problem != DRIFT_MISSING || res[1].Kind != DRIFT_REMOTE || res[1].Actual != nil {
+		t.Error("Remote binding should be missing ", res[1])
+	}
+	if res[2].Problem != DRIFT_EXTRA || res[2].Kind != DRIFT_TOPIC || res[2].Actual != stale || res[2].Service != s.Service {
+		t.Error("Old topic binding should be extra ", res[2])
+	}
+}
+
+func TestCompareBindingsDuplicates(t *testing.T) {
+	s := &domain.Service{Service: "com.HailoOSS.foo", Instance: "foo-1", AzName: "eu-west-1a", Vhost: "/"}
+	b := domain.BindingDefFromService(s)
+	dup := domain.BindingDefFromService(s)
+	dup.Arguments["x-weight"] = float64(2)
+	res := compareBindings("eu-west-1a", []*desiredBinding{{kind: DRIFT_LOCAL, service: s.Service, def: b}}, []*domain.BindingDef{dup, b}, nil)
+	if len(res) != 1 || res[0].Problem != DRIFT_EXTRA || res[0].Actual != dup {
+		t.Error("Binding with other arguments should be extra when the desired one exists ", res)
+	}
+	if driftKind(&domain.BindingDef{Source: raven.EXCHANGE, DestinationType: "exchange"}) != DRIFT_REMOTE {
+		t.Error("h2o to exchange should be a remote binding")
+	}
 }
 
-func TestHttpRequestCreation(t *testing.T) {
-	req, _ := createRequest(makeRabbitURL("bindings/%2f/e/h2o/q/1234", "localhost:15672"), "GET", nil)
-	if req.Method != "GET" {
-		t.Error("Method not set properly", req.Method)
+func TestDesiredBindings(t *testing.T) {
+	foo := &domain.Service{Service: "com.HailoOSS.foo", Instance: "foo-1", AzName: "eu-west-1a", Vhost: "/"}
+	bar1 := &domain.Service{Service: "com.HailoOSS.bar", Instance: "bar-1", AzName: "eu-west-1b", Vhost: "/"}
+	bar2 := &domain.Service{Service: "com.HailoOSS.bar", Instance: "bar-2", AzName: "eu-west-1b", Vhost: "/"}
+	baz := &domain.Service{Service: "com.HailoOSS.baz", Instance: "baz-1", AzName: "eu-west-1c", Vhost: "/"}
+	byAz := map[string][]*domain.Service{"eu-west-1a": {foo}, "eu-west-1b": {bar1, bar2}, "eu-west-1c": {baz}}
+	rules := map[string][]*domain.Rule{foo.Service: {{Service: foo.Service, Weight: 100}}}
+	// 1c has failed over so nothing sets up bindings to it
+	failed := map[string]bool{"eu-west-1c": true}
+
+	kinds := func(desired []*desiredBinding) map[string]int {
+		res := make(map[string]int)
+		for _, d := range desired {
+			res[d.kind]++
+		}
+		return res
 	}
-	if req.Host != "localhost:15672" {
-		t.Error("Host not set properly", req.Host)
+	desired, err := desiredBindings("eu-west-1a", byAz, failed, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := kinds(desired)
+	if len(desired) != 7 || k[DRIFT_LOCAL] != 1 || k[DRIFT_DIRECT] != 2 || k[DRIFT_REMOTE] != 1 || k[DRIFT_REMOTE_DIRECT] != 3 {
+		t.Error("Should be the local, direct and remote bindings to 1b, with the service bound once ", k)
 	}
-	if req.URL.RequestURI() != "http://localhost:15672/api/bindings/%2f/e/h2o/q/1234" {
-		t.Errorf("URI not set properly %+v", req.URL.RequestURI())
+	for _, d := range desired {
+		if d.kind != driftKind(d.def) {
+			t.Error("Desired binding has the wrong kind ", d.kind, d.def)
+		}
 	}
 
-	params := make(map[string]interface{})
-	params["foobar"] = "baz"
-	params["hello"] = "world"
-	req, _ = createRequest(makeRabbitURL("bindings/%2f/e/h2o/q/1234", "localhost:15672"), "POST", params)
-	if req.Method != "POST" {
-		t.Error("Method not set properly", req.Method)
+	// federation from 1a to 1b has been down long enough for 1b to stop binding it
+	defer func() { federation.status = FederationStatus{} }()
+	federation.status = FederationStatus{Checked: time.Now(), Links: []*FederationLink{
+		{AzName: "eu-west-1b", Vhost: "/", Upstream: "rabbit1", UpstreamAz: "eu-west-1a", Exchange: "eu-west-1b", Status: "error", Since: time.Now().Add(-time.Hour)},
+	}}
+	desired, err = desiredBindings("eu-west-1a", byAz, failed, rules)
+	if k := kinds(desired); err != nil || len(desired) != 3 || k[DRIFT_REMOTE] != 0 || k[DRIFT_REMOTE_DIRECT] != 0 {
+		t.Error("Shouldn't be bound to an AZ which can't receive federated messages from the cluster ", k, err)
 	}
-	if req.Host != "localhost:15672" {
-		t.Error("Host not set properly", req.Host)
+}
+
+func TestFailedOver(t *testing.T) {
+	toH2o := &domain.BindingDef{Source: "eu-west-1a", Vhost: "/", Destination: raven.EXCHANGE, DestinationType: "exchange"}
+	if failedOver([]*domain.BindingDef{toH2o}, "/", "eu-west-1a") {
+		t.Error("AZ exchange bound to h2o shouldn't be failed over")
 	}
-	if req.URL.RequestURI() != "http://localhost:15672/api/bindings/%2f/e/h2o/q/1234" {
-		t.Errorf("URI not set properly %+v", req.URL.RequestURI())
+	if !failedOver([]*domain.BindingDef{toH2o}, "/", "eu-west-1b") || !failedOver(nil, "/", "eu-west-1a") {
+		t.Error("AZ exchange not bound to h2o should be failed over")
 	}
+}
 
-	body, _ := ioutil.ReadAll(req.Body)
-	var res map[string]interface{}
-	json.Unmarshal(body, &res)
-	if res["foobar"].(string) != "baz" || res["hello"].(string) != "world" {
-		t.Error("Params not marshalled correctly")
+func TestCompareTopology(t *testing.T) {
+	clusters := map[string]*util.RabbitCluster{
+		"eu-west-1a": {AzName: "eu-west-1a", Nodes: []string{"rabbit1"}},
+		"eu-west-1b": {AzName: "eu-west-1b", Nodes: []string{"rabbit2:15672"}},
+	}
+	top := expectedTopology("eu-west-1a", clusters)
+	if len(top.upstreams) != 1 || top.upstreams["rabbit2"] == nil {
+		t.Fatal("Should be an upstream for the other cluster's node without its port ", top.upstreams)
+	}
+	exchanges := []*domain.ExchangeDef{
+		{Name: "h2o", Type: "headers", Durable: true},
+		{Name: "h2o.topic", Type: "fanout", Durable: true},
+		{Name: "h2o.direct", Type: "direct", Durable: true},
+		{Name: "eu-west-1a", Type: "headers", Durable: true},
+		{Name: "h2o.deadletter", Type: "headers", Durable: true},
+	}
+	upstreams := []*rabbit.Parameter{{Name: "rabbit2", Value: map[string]interface{}{"ack-mode": "on-confirm", "expires": float64(360000), "uri": "amqp://rabbit2"}}}
+	policies := []*rabbit.Policy{
+		{Name: "other", Pattern: "^eu-west-1a$", Definition: map[string]interface{}{"ha-mode": "all"}},
+		{Name: "h2o-alternate-exchange", Pattern: `^h2o$`, ApplyTo: "exchanges", Definition: map[string]interface{}{"alternate-exchange": "h2o.deadletter"}},
+	}
+	state := &vhostState{
+		exchanges: exchanges,
+		queues: []*rabbit.Queue{
+			{Name: "h2o.deadletter", Arguments: map[string]interface{}{"x-max-length": float64(10000)}},
+			{Name: "h2o.unroutable", Arguments: map[string]interface{}{"x-max-length": float64(1000), "x-message-ttl": float64(300000)}},
+		},
+		bindings: []*domain.BindingDef{
+			{Source: "h2o.deadletter", Vhost: "/", Destination: "h2o.deadletter", DestinationType: "queue", Arguments: map[string]interface{}{}},
+			{Source: "h2o.deadletter", Vhost: "/", Destination: "h2o.unroutable", DestinationType: "queue", Arguments: map[string]interface{}{}},
+		},
+		upstreams: upstreams,
+		policies:  policies,
 	}
 
-	req, _ = createRequest(makeRabbitURL("bindings/%2f/e/h2o/q/1234", "localhost:15672"), "DELETE", nil)
-	if req.Method != "DELETE" {
-		t.Error("Method not set properly", req.Method)
+	problems := compareTopology(top, "/", state)
+	expected := []string{
+		"exchange.eu-west-1b missing",
+		"exchange.h2o.direct.eu-west-1a missing",
+		"exchange.h2o.direct.eu-west-1b missing",
+		"exchange.h2o.topic type is fanout not topic",
+		"binding.h2o.direct.eu-west-1a->h2o.direct missing",
+		"upstream.rabbit2 ack-mode differs",
+		"policy.federate-eu-west-1a missing",
+		"policy.federate-h2o.direct.eu-west-1a missing",
 	}
-	if req.Host != "localhost:15672" {
-		t.Error("Host not set properly", req.Host)
+	if len(problems) != len(expected) {
+		t.Fatal("Wrong problems ", problems)
 	}
-	if req.URL.RequestURI() != "http://localhost:15672/api/bindings/%2f/e/h2o/q/1234" {
-		t.Errorf("URI not set properly %+v", req.URL.RequestURI())
+	for i, p := range problems {
+		if got := p.Kind + "." + p.Name + " " + p.Problem; got != expected[i] {
+			t.Error("Expected ", expected[i], " got ", got)
+		}
+	}
+
+	exchanges[1].Type = "topic"
+	state.exchanges = append(exchanges,
+		&domain.ExchangeDef{Name: "eu-west-1b", Type: "headers", Durable: true},
+		&domain.ExchangeDef{Name: "h2o.direct.eu-west-1a", Type: "fanout", Durable: true},
+		&domain.ExchangeDef{Name: "h2o.direct.eu-west-1b", Type: "fanout", Durable: true},
+	)
+	state.bindings = append(state.bindings, &domain.BindingDef{Source: "h2o.direct.eu-west-1a", Vhost: "/", Destination: "h2o.direct", DestinationType: "exchange"})
+	upstreams[0].Value["ack-mode"] = "no-ack"
+	state.policies = append(policies,
+		&rabbit.Policy{Name: "federate", Pattern: "^eu-west-1", Definition: map[string]interface{}{"federation-upstream-set": "all"}},
+		&rabbit.Policy{Name: "federate-h2o.direct.eu-west-1a", Pattern: `^h2o\.direct\.eu-west-1a$`, ApplyTo: "exchanges", Definition: map[string]interface{}{"federation-upstream-set": "all"}},
+	)
+	for _, p := range problems {
+		if isDirectProblem(p) != strings.Contains(p.Name, "h2o.direct") {
+			t.Error("Only the direct exchanges, binding and policy should be direct problems ", p.Key())
+		}
+	}
+	if problems := compareTopology(top, "/", state); len(problems) != 0 {
+		t.Error("Topology should match ", problems[0])
 	}
 }
 
-func TestCreateBinding(t *testing.T) {
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		defer r.Body.Close()
-		var bindingDef domain.BindingDef
-		bytes, _ := ioutil.ReadAll(r.Body)
-		err := json.Unmarshal(bytes, &bindingDef)
-		if err != nil {
-			t.Fail()
+func TestCompareDeadLetterTopology(t *testing.T) {
+	top := expectedTopology("eu-west-1a", map[string]*util.RabbitCluster{"eu-west-1a": {AzName: "eu-west-1a"}})
+	top.federate = ""
+	top.deadLetters = []*domain.DeadLetterRule{{Service: "com.HailoOSS.service.foo"}, {Service: "com.HailoOSS.service.bar", Vhost: "other"}}
+	state := &vhostState{
+		exchanges: []*domain.ExchangeDef{
+			{Name: "h2o", Type: "headers", Durable: true},
+			{Name: "h2o.topic", Type: "topic", Durable: true},
+			{Name: "h2o.direct", Type: "direct", Durable: true},
+			{Name: "h2o.deadletter", Type: "headers", Durable: true},
+			{Name: "eu-west-1a", Type: "headers", Durable: true},
+			{Name: "h2o.direct.eu-west-1a", Type: "fanout", Durable: true},
+		},
+		queues: []*rabbit.Queue{
+			{Name: "h2o.deadletter", Arguments: map[string]interface{}{"x-max-length": float64(500)}},
+			{Name: "h2o.unroutable", Arguments: map[string]interface{}{"x-max-length": float64(1000), "x-message-ttl": float64(300000)}},
+		},
+		bindings: []*domain.BindingDef{
+			{Source: "h2o.deadletter", Vhost: "/", Destination: "h2o.deadletter", DestinationType: "queue"},
+			{Source: "h2o.deadletter", Vhost: "/", Destination: "h2o.unroutable", DestinationType: "queue"},
+			{Source: "h2o.deadletter", Vhost: "/", Destination: "h2o.deadletter.com.HailoOSS.service.baz", DestinationType: "queue", RoutingKey: "com.HailoOSS.service.baz", Arguments: map[string]interface{}{"x-match": "all", "service": "com.HailoOSS.service.baz"}},
+		},
+		policies: []*rabbit.Policy{{Name: "h2o-alternate-exchange", Pattern: "^h2o$", ApplyTo: "exchanges", Definition: map[string]interface{}{"alternate-exchange": "h2o.dead"}}},
+	}
+
+	problems := compareTopology(top, "/", state)
+	expected := []string{
+		"queue.h2o.deadletter argument x-max-length is 500 not 10000",
+		"queue.h2o.deadletter.com.HailoOSS.service.foo missing",
+		"binding.h2o.deadletter->h2o.deadletter.com.HailoOSS.service.foo missing",
+		"binding.h2o.deadletter->h2o.deadletter.com.HailoOSS.service.baz stale",
+		"policy.h2o-alternate-exchange alternate-exchange is h2o.dead not h2o.deadletter",
+	}
+	if len(problems) != len(expected) {
+		t.Fatal("Wrong problems ", problems)
+	}
+	for i, p := range problems {
+		if got := p.Kind + "." + p.Name + " " + p.Problem; got != expected[i] {
+			t.Error("Expected ", expected[i], " got ", got)
 		}
-		fmt.Printf("Received request %#v\n", r)
+		if !isDeadLetterProblem(p) {
+			t.Error("Should be a dead-letter problem ", p.Key())
+		}
+	}
+}
 
-		switch {
-		case r.URL.Path == "/api/bindings///e/h2o/q/server-com.HailoOSS.service.foobar-1234567890":
-			v, ok := bindingDef.Arguments["service"]
-			if !ok {
-				t.Error("Missing 'service' argument")
+func TestFederationDown(t *testing.T) {
+	start := time.Now()
+	link := func(upstream, az, status string) *FederationLink {
+		return &FederationLink{AzName: "eu-west-1a", Vhost: "/", Upstream: upstream, UpstreamAz: az, Exchange: "eu-west-1a", Status: status}
+	}
+	links := mergeLinks(nil, []*FederationLink{link("rabbit2", "eu-west-1b", "error"), link("rabbit3", "eu-west-1b", LINK_MISSING), link("rabbit4", "eu-west-1c", "error")}, start)
+	if federationDown(links, "eu-west-1b", start.Add(time.Minute), 2*time.Minute) {
+		t.Error("Federation shouldn't be down before the links have been down long enough")
+	}
+	if !federationDown(links, "eu-west-1b", start.Add(3*time.Minute), 2*time.Minute) {
+		t.Error("Federation should be down once every link has been down long enough")
+	}
+	if federationDown(links, "eu-west-1d", start.Add(3*time.Minute), 2*time.Minute) {
+		t.Error("Federation shouldn't be down for an AZ without links")
+	}
 
-			}
-			if v.(string) != "com.HailoOSS.service.foobar" {
-				t.Error("'service' argument incorrect", v)
+	// a link which recovers resets when it was first seen with its status
+	later := start.Add(3 * time.Minute)
+	links = mergeLinks(links, []*FederationLink{link("rabbit2", "eu-west-1b", LINK_RUNNING), link("rabbit3", "eu-west-1b", LINK_MISSING)}, later)
+	if !links[0].Since.Equal(later) || !links[1].Since.Equal(start) {
+		t.Error("Since should only change with the status ", links[0].Since, links[1].Since)
+	}
+	if federationDown(links, "eu-west-1b", later.Add(time.Hour), 2*time.Minute) {
+		t.Error("Federation shouldn't be down while a link is running")
+	}
+}
 
-			}
-			v, ok = bindingDef.Arguments["x-match"]
-			if !ok {
-				t.Error("Missing 'x-match' argument")
+func TestFindOrphans(t *testing.T) {
+	protected, err := protectedQueues(`^federation: , ^amq\.`)
+	if err != nil || len(protected) != 2 {
+		t.Fatal("Protected patterns should parse ", protected, err)
+	}
+	if _, err := protectedQueues("(unclosed"); err == nil {
+		t.Error("Invalid protected pattern should be an error")
+	}
+	queues := []*rabbit.Queue{
+		{Name: "foo-1", Vhost: "/"},
+		{Name: "foo-2", Vhost: "/", Messages: 10},
+		{Name: "foo-3", Vhost: "/", Consumers: 1},
+		{Name: "foo-4", Vhost: "other"},
+		{Name: "federation: h2o -> rabbit2", Vhost: "/"},
+		{Name: "amq.gen-abc", Vhost: "/"},
+	}
+	res := findOrphans(queues, map[string]bool{"foo-1": true}, protected)
+	if len(res) != 1 || res[0].Name != "foo-2" || res[0].Messages != 10 {
+		t.Fatal("Only the unconsumed queue without an instance should be orphaned ", res)
+	}
 
+	// stands in for cassandra, shared by every instance
+	stored := make(map[string]time.Time)
+	tracker := &orphanTracker{
+		load: func(az string) (map[string]time.Time, error) {
+			res := make(map[string]time.Time, len(stored))
+			for k, v := range stored {
+				res[k] = v
 			}
-			if v.(string) != "all" {
-				t.Error("'x-match' argument incorrect", v)
+			return res, nil
+		},
+		save: func(az string, firstSeen map[string]time.Time, gone []string) error {
+			for k, v := range firstSeen {
+				stored[k] = v
 			}
-			w.WriteHeader(http.StatusOK)
-		default:
-			w.WriteHeader(http.StatusBadRequest)
-			t.Errorf("Error, incorrect URL called %#v", r.URL)
+			for _, k := range gone {
+				delete(stored, k)
+			}
+			return nil
+		},
+	}
+	start := time.Now()
+	tracker.track("eu-west-1a", res, start)
+	res = findOrphans(queues, map[string]bool{"foo-1": true}, protected)
+	tracker.track("eu-west-1a", res, start.Add(time.Minute))
+	if !res[0].FirstSeen.Equal(start) {
+		t.Error("First seen should be kept while the queue is orphaned ", res[0].FirstSeen)
+	}
+
+	// another instance sees the same times
+	other := &orphanTracker{load: tracker.load, save: func(string, map[string]time.Time, []string) error {
+		t.Error("Peeking shouldn't save anything")
+		return nil
+	}}
+	res = findOrphans(queues, map[string]bool{"foo-1": true}, protected)
+	other.peek("eu-west-1a", res, start.Add(90*time.Second))
+	if !res[0].FirstSeen.Equal(start) || gcAction(res[0], start.Add(*gcGrace)) != GC_COLLECT || gcAction(res[0], start.Add(time.Minute)) != GC_PENDING {
+		t.Error("Peeking should see when the queue was first orphaned ", res[0].FirstSeen)
+	}
+	other.peek("eu-west-1a", []*OrphanedQueue{{Vhost: "/", Name: "foo-5"}}, start.Add(90*time.Second))
+	if len(stored) != 1 {
+		t.Error("Peeking shouldn't track anything ", stored)
+	}
+
+	tracker.track("eu-west-1a", nil, start.Add(2*time.Minute))
+	if len(stored) != 0 {
+		t.Error("Queues which aren't orphaned should be forgotten ", stored)
+	}
+	res = findOrphans(queues, map[string]bool{"foo-1": true}, protected)
+	tracker.track("eu-west-1a", res, start.Add(3*time.Minute))
+	if !res[0].FirstSeen.Equal(start.Add(3 * time.Minute)) {
+		t.Error("First seen should be reset once the queue isn't orphaned ", res[0].FirstSeen)
+	}
+}
+
+func TestPurgeToken(t *testing.T) {
+	now := time.Now()
+	token := PurgeToken("eu-west-1a", "/", "foo-1", now)
+	if !validPurgeToken(token, "eu-west-1a", "/", "foo-1", now) {
+		t.Error("Token should be valid straight away")
+	}
+	if !validPurgeToken(token, "eu-west-1a", "/", "foo-1", now.Add(PURGE_TOKEN_WINDOW)) {
+		t.Error("Token should be valid in the next window")
+	}
+	if validPurgeToken(token, "eu-west-1a", "/", "foo-1", now.Add(2*PURGE_TOKEN_WINDOW)) {
+		t.Error("Token should expire")
+	}
+	if validPurgeToken(token, "eu-west-1a", "/", "foo-2", now) || validPurgeToken("", "eu-west-1a", "/", "foo-1", now) {
+		t.Error("Token should only be valid for its queue")
+	}
+}
+
+func TestAttributeUnroutable(t *testing.T) {
+	message := func(headers map[string]interface{}) *rabbit.Message {
+		m := &rabbit.Message{}
+		m.Properties.Headers = headers
+		return m
+	}
+	messages := []*rabbit.Message{
+		message(map[string]interface{}{"service": "com.HailoOSS.service.foo"}),
+		message(map[string]interface{}{"service": "com.HailoOSS.service.foo"}),
+		message(map[string]interface{}{"service": "com.HailoOSS.service.bar", "x-death": []interface{}{}}),
+		message(nil),
+	}
+	sampled, services := attributeUnroutable(messages)
+	if sampled != 3 || len(services) != 2 || services["com.HailoOSS.service.foo"] != 2 || services[UNKNOWN_SERVICE] != 1 {
+		t.Error("Wrong attribution ", sampled, services)
+	}
+
+	tracker := &unroutableTracker{services: make(map[string]bool)}
+	report := &UnroutableReport{AzName: "eu-west-1a", Vhost: "/", Services: services}
+	if changed := tracker.update([]*UnroutableReport{report}); len(changed) != 1 {
+		t.Error("New services should be reported ", changed)
+	}
+	if changed := tracker.update([]*UnroutableReport{report}); len(changed) != 0 {
+		t.Error("The same services shouldn't be reported again ", changed)
+	}
+	tracker.update([]*UnroutableReport{{AzName: "eu-west-1a", Vhost: "/", Services: map[string]int{}}})
+	if changed := tracker.update([]*UnroutableReport{report}); len(changed) != 1 {
+		t.Error("Services should be reported again once they've recovered ", changed)
+	}
+}
+
+func TestQueuePolicies(t *testing.T) {
+	rules := []*domain.QueuePolicyRule{
+		{Service: "com.HailoOSS.service.foo", MaxLength: 1000},
+		{Service: "com.HailoOSS.service.foo", Vhost: "other", MaxLength: 10},
+		{Service: "com.HailoOSS.service.bar", Vhost: "other", MessageTTL: 60000},
+	}
+	policies := queuePolicies(rules, "other")
+	foo := policies["binding-queue-com.HailoOSS.service.foo"]
+	if len(policies) != 2 || foo == nil || foo.Definition["max-length"] != int64(10) {
+		t.Fatal("The vhost's rule should win ", policies)
+	}
+	if foo.ApplyTo != "queues" || foo.Priority != 10 {
+		t.Error("Wrong policy ", foo)
+	}
+	re := regexp.MustCompile(foo.Pattern)
+	if !re.MatchString("server-com.HailoOSS.service.foo-1234567890") || re.MatchString("server-com.HailoOSS.service.foobar-1234567890") {
+		t.Error("Pattern should only match the service's instance queues ", foo.Pattern)
+	}
+
+	top := expectedTopology("eu-west-1a", map[string]*util.RabbitCluster{})
+	top.queuePolicies = rules
+	state := &vhostState{policies: []*rabbit.Policy{
+		{Name: "h2o-alternate-exchange", Pattern: "^h2o$", ApplyTo: "exchanges", Definition: map[string]interface{}{"alternate-exchange": "h2o.deadletter"}},
+		{Name: "binding-queue-com.HailoOSS.service.foo", Pattern: foo.Pattern, ApplyTo: "queues", Priority: 10, Definition: map[string]interface{}{"max-length": float64(1000), "message-ttl": float64(5)}},
+		{Name: "binding-queue-com.HailoOSS.service.baz", Pattern: "^server-baz-", ApplyTo: "queues", Definition: map[string]interface{}{}},
+	}}
+	got := make([]string, 0)
+	for _, p := range compareTopology(top, "/", state) {
+		if p.Kind == TOPOLOGY_POLICY && isQueuePolicy(p.Name) {
+			got = append(got, p.Name+" "+p.Problem)
 		}
-	}))
-	defer srv.Close()
-	srvURL := srv.URL[7:] // remove the leading http://
-	hc := &http.Client{}
-	b := &domain.BindingDef{Source: "h2o",
-		Vhost:           "/",
-		Destination:     "server-com.HailoOSS.service.foobar-1234567890",
-		DestinationType: string(domain.QUEUE),
-		RoutingKey:      "com.HailoOSS.service.foobar",
-		Arguments:       map[string]interface{}{"service": "com.HailoOSS.service.foobar", "x-match": "all"}}
-	err := CreateBinding(hc, srvURL, b)
-	if err != nil {
-		t.Error("Error creating binding ", err)
+	}
+	expected := []string{"binding-queue-com.HailoOSS.service.foo message-ttl is 5 not unset", "binding-queue-com.HailoOSS.service.baz stale"}
+	if len(got) != len(expected) || got[0] != expected[0] || got[1] != expected[1] {
+		t.Error("Expected ", expected, " got ", got)
+	}
+}
+
+func TestDirectBindings(t *testing.T) {
+	s := &domain.Service{Service: "com.HailoOSS.foo", Instance: "server-com.HailoOSS.foo-1", AzName: "eu-west-1a"}
+	local := directBindings(s)
+	if len(local) != 2 || local[0].RoutingKey != s.Instance || local[1].RoutingKey != s.Service {
+		t.Fatal("Instance should be bound by its ID and service ", local)
+	}
+	for _, b := range local {
+		if b.Source != DIRECT_EXCHANGE || b.Destination != s.Instance || b.DestinationType != "queue" || b.Arguments != nil {
+			t.Error("Wrong local direct binding ", b)
+		}
+	}
+	remote := remoteDirectBindings(s, "eu-west-1a")
+	if len(remote) != 2 || remote[0].RoutingKey != s.Instance || remote[1].RoutingKey != s.Service {
+		t.Fatal("Remote bindings should be by ID and service ", remote)
+	}
+	for _, b := range remote {
+		if b.Source != DIRECT_EXCHANGE || b.Destination != "h2o.direct.eu-west-1a" || b.DestinationType != "exchange" {
+			t.Error("Wrong remote direct binding ", b)
+		}
+	}
+	if b := directAzBinding("/", "eu-west-1a"); b.Source != "h2o.direct.eu-west-1a" || b.Destination != DIRECT_EXCHANGE {
+		t.Error("AZ's direct exchange should be bound to h2o.direct ", b)
+	}
+	if p := directFederationPolicy("eu-west-1a"); !isFederated("h2o.direct.eu-west-1a", []*rabbit.Policy{p}) || isFederated("h2o.direct.eu-west-1b", []*rabbit.Policy{p}) {
+		t.Error("Policy should only federate the AZ's direct exchange ", p.Pattern)
 	}
 }
@@ -0,0 +1,130 @@
+package binding
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/rabbit"
+	"github.com/HailoOSS/platform/raven"
+)
+
+var deadLetterMaxLength = flag.Int("binding_deadletter_max_length", 10000, "Maximum messages kept in each dead-letter queue, the oldest are dropped")
+
+const (
+	DEADLETTER_EXCHANGE = "h2o.deadletter"
+	DEADLETTER_QUEUE    = "h2o.deadletter"         // gets every dead-lettered message, services' own queues are named after it
+	DEADLETTER_POLICY   = "h2o-alternate-exchange" // makes the dead-letter exchange the alternate exchange of h2o
+	MAX_REPLAY_MESSAGES = 100
+	DEFAULT_EXCHANGE    = "amq.default" // how the management API names the default exchange
+)
+
+// DeadLetterQueue returns the name of the queue with a service's dead-lettered messages
+func DeadLetterQueue(service string) string {
+	return DEADLETTER_QUEUE + "." + service
+}
+
+// deadLetterPolicy sends the messages h2o can't route to the dead-letter exchange
+func deadLetterPolicy() *rabbit.Policy {
+	return &rabbit.Policy{
+		Name:       DEADLETTER_POLICY,
+		Pattern:    "^" + regexp.QuoteMeta(raven.EXCHANGE) + "$",
+		ApplyTo:    "exchanges",
+		Definition: map[string]interface{}{"alternate-exchange": DEADLETTER_EXCHANGE},
+	}
+}
+
+func deadLetterQueueArgs() map[string]interface{} {
+	return map[string]interface{}{"x-max-length": *deadLetterMaxLength}
+}
+
+// deadLetterBinding binds the queue to the dead-letter exchange. The catch-all queue gets everything, a service's
+// queue only gets the messages with its service header.
+func deadLetterBinding(vhost string, queue string) *domain.BindingDef {
+	b := &domain.BindingDef{Source: DEADLETTER_EXCHANGE, Vhost: vhost, Destination: queue, DestinationType: string(domain.QUEUE)}
+	if service := strings.TrimPrefix(queue, DEADLETTER_QUEUE+"."); service != queue {
+		b.RoutingKey = service
+		b.Arguments = map[string]interface{}{"x-match": "all", "service": service}
+	}
+	return b
+}
+
+func isDeadLetterProblem(p *TopologyProblem) bool {
+	return strings.HasPrefix(p.Name, DEADLETTER_EXCHANGE) || p.Name == DEADLETTER_POLICY || p.Name == UNROUTABLE_QUEUE
+}
+
+// deadLetterQueueFor returns the service's dead-letter queue, or the catch-all one without a service
+func deadLetterQueueFor(service string) string {
+	if service == "" {
+		return DEADLETTER_QUEUE
+	}
+	return DeadLetterQueue(service)
+}
+
+// ListDeadLetters returns a dead-letter queue on the cluster in azName with up to count messages from its head, which
+// are requeued. It's the service's own queue if a service is given. The vhost defaults to the one this service is in.
+func ListDeadLetters(ctx context.Context, azName string, vhost string, service string, count int) (*QueueInspection, error) {
+	if vhost == "" {
+		vhost = localVhost()
+	}
+	return InspectQueue(ctx, azName, vhost, deadLetterQueueFor(service), count)
+}
+
+// ReplayResult is the outcome of replaying dead-lettered messages
+type ReplayResult struct {
+	AzName   string
+	Vhost    string
+	Queue    string
+	Replayed int // routed by h2o
+	Unrouted int // published but not routed to any queue
+	Failed   int // couldn't be published and were put back on the queue
+	Lost     int // couldn't be published or put back
+}
+
+// ReplayDeadLetters takes up to count messages from the head of a dead-letter queue on the cluster in azName and
+// publishes them to h2o again. Messages which can't be published are put back at the tail of the queue.
+func ReplayDeadLetters(ctx context.Context, azName string, vhost string, service string, count int) (*ReplayResult, error) {
+	azName, host, err := clusterHost(azName)
+	if err != nil {
+		return nil, err
+	}
+	if vhost == "" {
+		vhost = localVhost()
+	}
+	if count <= 0 || count > MAX_REPLAY_MESSAGES {
+		count = MAX_REPLAY_MESSAGES
+	}
+	res := &ReplayResult{AzName: azName, Vhost: vhost, Queue: deadLetterQueueFor(service)}
+	messages, err := getRabbitClient().TakeMessages(ctx, host, vhost, res.Queue, count)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range messages {
+		routed, err := getRabbitClient().Publish(ctx, host, vhost, raven.EXCHANGE, m)
+		switch {
+		case err == nil && routed:
+			res.Replayed++
+		case err == nil:
+			res.Unrouted++
+		default:
+			log.Errorf("Error replaying a message from %s in vhost %s on %s %+v", res.Queue, vhost, azName, err)
+			back := *m
+			back.Routing_key = res.Queue
+			if _, err := getRabbitClient().Publish(ctx, host, vhost, DEFAULT_EXCHANGE, &back); err != nil {
+				log.Errorf("Error putting a message back on %s in vhost %s on %s, it's lost %+v", res.Queue, vhost, azName, err)
+				res.Lost++
+			} else {
+				res.Failed++
+			}
+		}
+	}
+	log.Infof("Replayed %d messages from %s in vhost %s on %s: %+v", len(messages), res.Queue, vhost, azName, res)
+	if res.Lost > 0 {
+		return res, fmt.Errorf("%d messages from %s couldn't be replayed or put back", res.Lost, res.Queue)
+	}
+	return res, nil
+}
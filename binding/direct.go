@@ -0,0 +1,156 @@
+package binding
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/rabbit"
+	"github.com/HailoOSS/platform/errors"
+)
+
+// Messages published to h2o.direct are routed by routing key, either an instance ID for replies and calls to an
+// instance, or a service name. The AZ exchanges are headers exchanges so they can't carry them to other AZs. Instead
+// each AZ has a federated fanout exchange, h2o.direct.<az>, which the other clusters bind h2o.direct to for the AZ's
+// instances and which is bound to h2o.direct on the AZ's own cluster. Federation's max hops stops the messages going
+// round in circles.
+
+const DIRECT_AZ_EXCHANGE_TYPE = "fanout"
+
+// DirectAzExchange returns the name of the exchange carrying direct messages to the AZ
+func DirectAzExchange(azName string) string {
+	return DIRECT_EXCHANGE + "." + azName
+}
+
+// directKeys are the routing keys an instance is bound by
+func directKeys(s *domain.Service) []string {
+	return []string{s.Instance, s.Service}
+}
+
+// directBindings bind the instance's queue to h2o.direct by its instance ID and its service
+func directBindings(s *domain.Service) []*domain.BindingDef {
+	res := make([]*domain.BindingDef, 0, 2)
+	for _, key := range directKeys(s) {
+		res = append(res, &domain.BindingDef{Source: DIRECT_EXCHANGE, Vhost: s.GetVhost(), Destination: s.Instance, DestinationType: string(domain.QUEUE), RoutingKey: key})
+	}
+	return res
+}
+
+// remoteDirectBindings bind h2o.direct on another cluster to the AZ's direct exchange for the instance
+func remoteDirectBindings(s *domain.Service, azName string) []*domain.BindingDef {
+	res := make([]*domain.BindingDef, 0, 2)
+	for _, key := range directKeys(s) {
+		res = append(res, &domain.BindingDef{Source: DIRECT_EXCHANGE, Vhost: s.GetVhost(), Destination: DirectAzExchange(azName), DestinationType: string(domain.EXCHANGE), RoutingKey: key})
+	}
+	return res
+}
+
+// directAzBinding delivers the direct messages federated to the AZ to h2o.direct on its cluster
+func directAzBinding(vhost string, azName string) *domain.BindingDef {
+	return &domain.BindingDef{Source: DirectAzExchange(azName), Vhost: vhost, Destination: DIRECT_EXCHANGE, DestinationType: string(domain.EXCHANGE)}
+}
+
+// directFederationPolicy federates the AZ's direct exchange from all the upstreams
+func directFederationPolicy(azName string) *rabbit.Policy {
+	return &rabbit.Policy{
+		Name:       fmt.Sprintf(FEDERATION_POLICY, DirectAzExchange(azName)),
+		Pattern:    "^" + regexp.QuoteMeta(DirectAzExchange(azName)) + "$",
+		ApplyTo:    "exchanges",
+		Definition: map[string]interface{}{"federation-upstream-set": "all"},
+	}
+}
+
+func isDirectProblem(p *TopologyProblem) bool {
+	return strings.HasPrefix(p.Name, DIRECT_EXCHANGE) || strings.HasPrefix(p.Name, fmt.Sprintf(FEDERATION_POLICY, DIRECT_EXCHANGE))
+}
+
+// GetDirectBindings returns the bindings from h2o.direct to the queue or exchange
+func GetDirectBindings(ctx context.Context, host string, vhost string, toType domain.DestinationTypeS, to string) ([]*domain.BindingDef, error) {
+	return getRabbitClient().GetBindingsBetween(ctx, host, vhost, DIRECT_EXCHANGE, toType, to)
+}
+
+// Use to delete h2o.direct -> service bindings
+func DeleteDirectServiceBindings(ctx context.Context, host string, vhost string, instanceId string) error {
+	bindings, err := GetDirectBindings(ctx, host, vhost, domain.QUEUE_S, instanceId)
+	if rabbit.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		log.Error("Failed to find direct bindings ", err)
+		return err
+	}
+	for _, b := range bindings {
+		// ignore errors because queue is most likely gone anyway
+		getRabbitClient().DeleteBinding(ctx, host, b)
+	}
+	return nil
+}
+
+// Use to delete the bindings on a broker from h2o.direct to the AZ's direct exchange with the routing key, every one
+// if the key is empty
+func DeleteRemoteDirectBindings(ctx context.Context, host string, vhost string, key string, azName string) error {
+	bindings, err := GetDirectBindings(ctx, host, vhost, domain.EXCHANGE_S, DirectAzExchange(azName))
+	if err != nil {
+		if rabbit.IsNotFound(err) {
+			return nil
+		}
+		log.Error("Failed to find direct bindings ", err)
+		return err
+	}
+	for _, b := range bindings {
+		if key == "" || b.RoutingKey == key {
+			getRabbitClient().DeleteBinding(ctx, host, b)
+		}
+	}
+	return nil
+}
+
+// teardownDirect removes the instance's h2o.direct bindings on this cluster and, unless its service is local, its
+// bindings on the other clusters. The service's bindings on the other clusters go with its last instance, see
+// teardownRemote.
+func teardownDirect(ctx context.Context, service string, instance string, azName string) errors.Error {
+	for _, vhost := range Vhosts() {
+		if err := DeleteDirectServiceBindings(ctx, LocalHost, vhost, instance); err != nil {
+			return errors.InternalServerError("com.HailoOSS.kernel.binding.teardownservice", fmt.Sprintf("Error while deleting direct bindings to %v %v", instance, err))
+		}
+	}
+	if localServices[service] {
+		return nil
+	}
+	hosts, err := getRabbitClusterHosts()
+	if err != nil {
+		return errors.InternalServerError("com.HailoOSS.kernel.binding.teardownservice", fmt.Sprintf("Error while retrieving hostnames %v", err))
+	}
+	for _, host := range hosts {
+		if host.AzName == azName {
+			continue
+		}
+		for _, vhost := range Vhosts() {
+			if err := DeleteRemoteDirectBindings(ctx, host.Host, vhost, instance, azName); err != nil {
+				return errors.InternalServerError("com.HailoOSS.kernel.binding.teardownservice", fmt.Sprintf("Error while deleting direct bindings to %v on %v %v", instance, host.AzName, err))
+			}
+		}
+	}
+	return nil
+}
+
+// teardownMissingDirectInVhost removes the bindings on this cluster from h2o.direct to the AZ's direct exchange whose
+// instance or service isn't running in the AZ
+func teardownMissingDirectInVhost(ctx context.Context, vhost string, azName string, remoteRunning map[string]*domain.Service, remoteInstances map[string]bool) {
+	bindings, err := GetDirectBindings(ctx, LocalHost, vhost, domain.EXCHANGE_S, DirectAzExchange(azName))
+	if err != nil {
+		if !rabbit.IsNotFound(err) {
+			log.Errorf("Error getting direct bindings for %s in vhost %s %+v", azName, vhost, err)
+		}
+		return
+	}
+	for _, b := range bindings {
+		if _, ok := remoteRunning[azName+b.RoutingKey]; ok || remoteInstances[azName+b.RoutingKey] {
+			continue
+		}
+		log.Debugf("Deleting direct binding for missing instance or service %+v", b)
+		getRabbitClient().DeleteBinding(ctx, LocalHost, b)
+	}
+}
@@ -0,0 +1,78 @@
+package binding
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/rabbit"
+	"github.com/HailoOSS/binding-service/util"
+)
+
+var (
+	clusterDiscovery  = flag.Bool("binding_cluster_discovery", true, "Discover the nodes of each cluster from the management API, using the nodes in the rabbit hosts file as seeds")
+	discoveryInterval = flag.Duration("binding_cluster_discovery_interval", time.Minute, "Interval between refreshes of the discovered cluster nodes")
+)
+
+// runClusterDiscovery periodically refreshes the nodes of every cluster
+func runClusterDiscovery() {
+	if !*clusterDiscovery {
+		return
+	}
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), *discoveryInterval)
+		discoverClusters(ctx)
+		cancel()
+		time.Sleep(*discoveryInterval)
+	}
+}
+
+func discoverClusters(ctx context.Context) {
+	cs, err := util.GetConfiguredRabbitClusters()
+	if err != nil {
+		log.Errorf("Error while retrieving rabbit clusters to discover, %+v", err)
+		return
+	}
+	for az, c := range cs {
+		nodes, err := discoverNodes(ctx, c)
+		discoveryTotal.Inc(az, resultLabel(err != nil))
+		if err != nil {
+			// the configured nodes are used once the last discovered ones expire
+			log.Warnf("Error discovering the nodes of cluster %s: %v", az, err)
+			continue
+		}
+		util.SetDiscoveredNodes(az, nodes)
+	}
+}
+
+// discoverNodes asks the cluster's seeds for its running nodes
+func discoverNodes(ctx context.Context, c *util.RabbitCluster) ([]string, error) {
+	seed := getRabbitClient().PickNode(c.ManagementHosts())
+	ns, err := getRabbitClient().GetNodes(ctx, seed)
+	if err != nil {
+		return nil, err
+	}
+	return runningNodeHosts(ns)
+}
+
+// runningNodeHosts returns the hostnames of the running nodes, rabbit names its nodes name@hostname
+func runningNodeHosts(ns []*rabbit.Node) ([]string, error) {
+	hosts := make([]string, 0, len(ns))
+	for _, n := range ns {
+		if !n.Running {
+			continue
+		}
+		i := strings.LastIndex(n.Name, "@")
+		if i < 0 || i == len(n.Name)-1 {
+			return nil, fmt.Errorf("Unexpected rabbit node name %s", n.Name)
+		}
+		hosts = append(hosts, n.Name[i+1:])
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("No running nodes")
+	}
+	return hosts, nil
+}
@@ -0,0 +1,322 @@
+package binding
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/platform/raven"
+)
+
+// kinds of binding compared in a drift report
+const (
+	DRIFT_LOCAL         = "local"        // h2o -> instance queue
+	DRIFT_TOPIC         = "topic"        // h2o.topic -> instance queue
+	DRIFT_REMOTE        = "remote"       // h2o -> AZ exchange
+	DRIFT_DIRECT        = "direct"       // h2o.direct -> instance queue
+	DRIFT_REMOTE_DIRECT = "remotedirect" // h2o.direct -> AZ direct exchange
+)
+
+// problems found in a drift report
+const (
+	DRIFT_MISSING    = "missing"
+	DRIFT_EXTRA      = "extra"
+	DRIFT_MISMATCHED = "mismatched"
+)
+
+// Drift is a difference between the bindings a cluster should have and the ones it does
+type Drift struct {
+	AzName   string
+	Kind     string
+	Problem  string
+	Service  string
+	Expected *domain.BindingDef // nil for extra bindings
+	Actual   *domain.BindingDef // nil for missing bindings
+}
+
+// ClusterDrift summarises the differences found on a cluster
+type ClusterDrift struct {
+	AzName     string
+	Host       string
+	Err        error // set if the cluster's bindings couldn't be retrieved
+	Missing    int
+	Extra      int
+	Mismatched int
+}
+
+// DriftReport is the differences between the desired and actual bindings of every cluster
+type DriftReport struct {
+	Clusters    []*ClusterDrift
+	Differences []*Drift
+	Missing     int
+	Extra       int
+	Mismatched  int
+}
+
+// desiredBinding is a binding a cluster should have
+type desiredBinding struct {
+	kind    string
+	service string
+	def     *domain.BindingDef
+}
+
+// GetDrift compares the bindings every cluster should have, given the running instances and the current rules,
+// against the ones they have. It's limited to the cluster in azName and bindings for service if they're given.
+func GetDrift(ctx context.Context, azName string, service string) (*DriftReport, error) {
+	hosts, err := getRabbitClusterHosts()
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving hostnames %v", err)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].AzName < hosts[j].AzName })
+	services, err := GetInstances()
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving instances %v", err)
+	}
+
+	// the vhost of each instance is wherever its queue is bound on its own cluster, so get all the bindings first
+	actual := make(map[string][]*domain.BindingDef)
+	errs := make(map[string]error)
+	for _, host := range hosts {
+		bindings, err := getRabbitClient().GetBindings(ctx, host.Host)
+		if err != nil {
+			errs[host.AzName] = err
+			continue
+		}
+		managed := make([]*domain.BindingDef, 0, len(bindings))
+		for _, b := range bindings {
+			if IsManagedVhost(b.Vhost) {
+				managed = append(managed, b)
+			}
+		}
+		actual[host.AzName] = managed
+	}
+	byAz := make(map[string][]*domain.Service)
+	for _, s := range services {
+		vs := *s
+		vs.Vhost = boundVhost(actual[s.AzName], s.Instance)
+		byAz[s.AzName] = append(byAz[s.AzName], &vs)
+	}
+	// an az whose bindings couldn't be retrieved is assumed not to have failed over, as when setting up
+	failed := make(map[string]bool)
+	for az, bindings := range actual {
+		failed[az] = failedOver(bindings, localVhost(), az)
+	}
+
+	rules := make(map[string][]*domain.Rule)
+	report := &DriftReport{}
+	for _, host := range hosts {
+		if azName != "" && host.AzName != azName {
+			continue
+		}
+		cd := &ClusterDrift{AzName: host.AzName, Host: host.Host, Err: errs[host.AzName]}
+		report.Clusters = append(report.Clusters, cd)
+		if cd.Err != nil {
+			log.Errorf("Error getting bindings of cluster %s for drift report %+v", host.AzName, cd.Err)
+			continue
+		}
+		desired, err := desiredBindings(host.AzName, byAz, failed, rules)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range compareBindings(host.AzName, desired, actual[host.AzName], queueServices(services)) {
+			if service != "" && d.Service != service {
+				continue
+			}
+			switch d.Problem {
+			case DRIFT_MISSING:
+				cd.Missing++
+			case DRIFT_EXTRA:
+				cd.Extra++
+			case DRIFT_MISMATCHED:
+				cd.Mismatched++
+			}
+			report.Differences = append(report.Differences, d)
+		}
+		report.Missing += cd.Missing
+		report.Extra += cd.Extra
+		report.Mismatched += cd.Mismatched
+	}
+	return report, nil
+}
+
+// boundVhost returns the vhost the queue is bound in, or our vhost if it isn't bound
+func boundVhost(bindings []*domain.BindingDef, queue string) string {
+	for _, b := range bindings {
+		if b.Destination == queue && b.DestinationType == string(domain.QUEUE) {
+			return b.Vhost
+		}
+	}
+	return localVhost()
+}
+
+// queueServices maps each instance's queue, which is named after its ID, to its service
+func queueServices(services []*domain.Service) map[string]string {
+	res := make(map[string]string)
+	for _, s := range services {
+		res[s.Instance] = s.Service
+	}
+	return res
+}
+
+// desiredBindings returns the bindings the cluster in azName should have, following the same rules as setting up the
+// instances in each az does. Rules are cached by service.
+func desiredBindings(azName string, byAz map[string][]*domain.Service, failed map[string]bool, rules map[string][]*domain.Rule) ([]*desiredBinding, error) {
+	res := make([]*desiredBinding, 0)
+	for _, s := range byAz[azName] {
+		r, ok := rules[s.Service]
+		if !ok {
+			var errObj error
+			r, errObj = getServiceRules(s)
+			if errObj != nil {
+				return nil, errObj
+			}
+			rules[s.Service] = r
+		}
+		res = append(res, &desiredBinding{kind: DRIFT_LOCAL, service: s.Service, def: localBinding(s, r)})
+		for _, b := range directBindings(s) {
+			res = append(res, &desiredBinding{kind: DRIFT_DIRECT, service: s.Service, def: b})
+		}
+		for _, sub := range s.Subscriptions {
+			if sub != "" {
+				res = append(res, &desiredBinding{kind: DRIFT_TOPIC, service: s.Service, def: topicBindingDef(s.GetVhost(), s.Instance, sub)})
+			}
+		}
+	}
+
+	// every other AZ running a service should be bound to, once per vhost, unless it wouldn't set up the bindings
+	seen := make(map[string]bool)
+	for az, services := range byAz {
+		for _, s := range services {
+			if !remoteBound(s.Service, azName, az, failed[az]) {
+				continue
+			}
+			for _, b := range remoteBindings(s, az) {
+				if seen[bindingKey(b)] {
+					continue
+				}
+				seen[bindingKey(b)] = true
+				res = append(res, &desiredBinding{kind: driftKind(b), service: s.Service, def: b})
+			}
+		}
+	}
+	return res, nil
+}
+
+func getServiceRules(s *domain.Service) ([]*domain.Rule, error) {
+	rules, errObj := getRules(s)
+	if errObj != nil {
+		return nil, fmt.Errorf("Error while retrieving rules for %s %s", s.Service, errObj.Description())
+	}
+	return rules, nil
+}
+
+func topicBindingDef(vhost string, queue string, topic string) *domain.BindingDef {
+	return &domain.BindingDef{Source: raven.TOPIC_EXCHANGE, Vhost: vhost, Destination: queue, DestinationType: string(domain.QUEUE), RoutingKey: topic}
+}
+
+// driftKind returns the kind of binding, or "" if it isn't one the binding service manages
+func driftKind(b *domain.BindingDef) string {
+	switch {
+	case b.Source == raven.EXCHANGE && b.DestinationType == string(domain.QUEUE):
+		return DRIFT_LOCAL
+	case b.Source == raven.TOPIC_EXCHANGE && b.DestinationType == string(domain.QUEUE):
+		return DRIFT_TOPIC
+	case b.Source == raven.EXCHANGE && b.DestinationType == string(domain.EXCHANGE):
+		return DRIFT_REMOTE
+	case b.Source == DIRECT_EXCHANGE && b.DestinationType == string(domain.QUEUE):
+		return DRIFT_DIRECT
+	case b.Source == DIRECT_EXCHANGE && b.DestinationType == string(domain.EXCHANGE) && strings.HasPrefix(b.Destination, DIRECT_EXCHANGE+"."):
+		return DRIFT_REMOTE_DIRECT
+	}
+	return ""
+}
+
+func bindingKey(b *domain.BindingDef) string {
+	return b.Vhost + "|" + b.Source + "|" + b.DestinationType + "|" + b.Destination + "|" + b.RoutingKey
+}
+
+// compareBindings returns the differences between the desired and actual bindings of a cluster. Bindings with the
+// same source, destination and routing key but different arguments are mismatched, any duplicates are extra.
+func compareBindings(azName string, desired []*desiredBinding, actual []*domain.BindingDef, queues map[string]string) []*Drift {
+	groups := make(map[string][]*domain.BindingDef)
+	keys := make([]string, 0)
+	for _, b := range actual {
+		if driftKind(b) == "" {
+			continue
+		}
+		k := bindingKey(b)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], b)
+	}
+
+	res := make([]*Drift, 0)
+	wanted := make(map[string]bool)
+	for _, d := range desired {
+		k := bindingKey(d.def)
+		wanted[k] = true
+		group := groups[k]
+		if len(group) == 0 {
+			res = append(res, &Drift{AzName: azName, Kind: d.kind, Problem: DRIFT_MISSING, Service: d.service, Expected: d.def})
+			continue
+		}
+		match := -1
+		for i, b := range group {
+			if sameBinding(d.def, b) {
+				match = i
+				break
+			}
+		}
+		if match < 0 {
+			res = append(res, &Drift{AzName: azName, Kind: d.kind, Problem: DRIFT_MISMATCHED, Service: d.service, Expected: d.def, Actual: group[0]})
+			match = 0
+		}
+		for i, b := range group {
+			if i != match {
+				res = append(res, &Drift{AzName: azName, Kind: d.kind, Problem: DRIFT_EXTRA, Service: d.service, Actual: b})
+			}
+		}
+	}
+	for _, k := range keys {
+		if wanted[k] {
+			continue
+		}
+		for _, b := range groups[k] {
+			res = append(res, &Drift{AzName: azName, Kind: driftKind(b), Problem: DRIFT_EXTRA, Service: bindingService(b, queues), Actual: b})
+		}
+	}
+	return res
+}
+
+// bindingService returns the service a binding is for, from its arguments, the instance queue it's bound to or, for a
+// direct binding to another AZ, its routing key
+func bindingService(b *domain.BindingDef, queues map[string]string) string {
+	if s, ok := b.Arguments["service"].(string); ok {
+		return s
+	}
+	if s, ok := queues[b.Destination]; ok {
+		return s
+	}
+	if s, ok := queues[b.RoutingKey]; ok {
+		return s
+	}
+	if driftKind(b) == DRIFT_REMOTE_DIRECT {
+		// bound by service, or an instance discovery no longer knows which is reported under its ID
+		return b.RoutingKey
+	}
+	return ""
+}
+
+// sameBinding compares the bindings, rabbit returns empty arguments for bindings created without any
+func sameBinding(a *domain.BindingDef, b *domain.BindingDef) bool {
+	if len(a.Arguments) == 0 && len(b.Arguments) == 0 {
+		ac, bc := *a, *b
+		ac.Arguments, bc.Arguments = nil, nil
+		return ac.Equals(&bc)
+	}
+	return a.Equals(b)
+}
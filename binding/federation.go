@@ -0,0 +1,219 @@
+package binding
+
+import (
+	"context"
+	"flag"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/util"
+)
+
+var (
+	federationPoll      = flag.Duration("binding_federation_poll", 30*time.Second, "How often the status of the federation links on every cluster is checked")
+	federationDownAfter = flag.Duration("binding_federation_down_after", 2*time.Minute, "How long all our federation links from an AZ have to be down before we stop binding it to this AZ, 0 to never")
+)
+
+const (
+	LINK_RUNNING = "running"
+	LINK_MISSING = "missing" // an upstream without a link
+)
+
+// FederationLink is the state of the link federating a cluster's AZ exchange from an upstream node
+type FederationLink struct {
+	AzName     string // the cluster the link is on
+	Vhost      string
+	Upstream   string
+	UpstreamAz string // the cluster the upstream node is in, if it's known
+	Exchange   string
+	Status     string
+	Error      string
+	Since      time.Time // when the link was first seen with its status
+}
+
+func (l *FederationLink) key() string {
+	return l.AzName + "|" + l.Vhost + "|" + l.Upstream + "|" + l.Exchange
+}
+
+// FederationStatus is the last known state of the federation links on every cluster
+type FederationStatus struct {
+	Checked time.Time
+	Links   []*FederationLink
+	Errs    map[string]error // clusters whose links couldn't be retrieved, keyed by az
+}
+
+type federationMonitor struct {
+	sync.RWMutex
+	status FederationStatus
+}
+
+var federation = &federationMonitor{}
+
+// runFederationMonitor periodically polls the federation links of every cluster
+func runFederationMonitor() {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), *federationPoll)
+		pollFederationLinks(ctx)
+		cancel()
+		time.Sleep(*federationPoll)
+	}
+}
+
+func pollFederationLinks(ctx context.Context) {
+	clusters, err := util.GetRabbitClusters()
+	if err != nil {
+		log.Errorf("Error while retrieving rabbit clusters to check federation, %+v", err)
+		return
+	}
+	// standby clusters aren't federated to until they're made primary
+	primary := make(map[string]*util.RabbitCluster, len(clusters))
+	for az, c := range clusters {
+		if !c.IsStandby() {
+			primary[az] = c
+		}
+	}
+	links := make([]*FederationLink, 0)
+	errs := make(map[string]error)
+	for az, c := range primary {
+		ls, err := getClusterLinks(ctx, az, c, primary)
+		if err != nil {
+			log.Warnf("Error retrieving the federation links of cluster %s: %v", az, err)
+			errs[az] = err
+			// keep what we knew, a cluster we can't reach isn't evidence its links are down
+			ls = federation.get("").clusterLinks(az)
+		}
+		links = append(links, ls...)
+	}
+
+	federation.Lock()
+	defer federation.Unlock()
+	federation.status = FederationStatus{Checked: time.Now(), Links: mergeLinks(federation.status.Links, links, time.Now()), Errs: errs}
+}
+
+// getClusterLinks returns the links on the cluster, with a missing link for each upstream it should have but doesn't.
+// Links from standby clusters are left out.
+func getClusterLinks(ctx context.Context, az string, c *util.RabbitCluster, clusters map[string]*util.RabbitCluster) ([]*FederationLink, error) {
+	host := getRabbitClient().PickNode(c.ManagementHosts())
+	expected := expectedTopology(az, clusters)
+	res := make([]*FederationLink, 0)
+	for _, vhost := range Vhosts() {
+		ls, err := getRabbitClient().GetFederationLinks(ctx, host, vhost)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool)
+		for _, l := range ls {
+			if uc, ok := util.GetRabbitCluster(l.Upstream); ok && uc.IsStandby() {
+				continue
+			}
+			seen[l.Upstream] = true
+			res = append(res, &FederationLink{AzName: az, Vhost: vhost, Upstream: l.Upstream, UpstreamAz: upstreamAz(l.Upstream), Exchange: l.Exchange, Status: l.Status, Error: l.Error})
+		}
+		for upstream := range expected.upstreams {
+			if !seen[upstream] {
+				res = append(res, &FederationLink{AzName: az, Vhost: vhost, Upstream: upstream, UpstreamAz: upstreamAz(upstream), Exchange: expected.federate, Status: LINK_MISSING})
+			}
+		}
+	}
+	return res, nil
+}
+
+func upstreamAz(upstream string) string {
+	if c, ok := util.GetRabbitCluster(upstream); ok {
+		return c.AzName
+	}
+	return ""
+}
+
+// mergeLinks sorts the links and carries over when each was first seen with its status
+func mergeLinks(prev []*FederationLink, next []*FederationLink, now time.Time) []*FederationLink {
+	byKey := make(map[string]*FederationLink, len(prev))
+	for _, l := range prev {
+		byKey[l.key()] = l
+	}
+	for _, l := range next {
+		if p, ok := byKey[l.key()]; ok && p.Status == l.Status && l.Since.IsZero() {
+			l.Since = p.Since
+		} else if l.Since.IsZero() {
+			l.Since = now
+		}
+	}
+	sort.Slice(next, func(i, j int) bool { return next[i].key() < next[j].key() })
+	return next
+}
+
+// get returns the status, only of the cluster in azName if it's given
+func (m *federationMonitor) get(azName string) *FederationStatus {
+	m.RLock()
+	defer m.RUnlock()
+	res := &FederationStatus{Checked: m.status.Checked, Links: make([]*FederationLink, 0), Errs: make(map[string]error)}
+	for _, l := range m.status.Links {
+		if azName == "" || l.AzName == azName {
+			lc := *l
+			res.Links = append(res.Links, &lc)
+		}
+	}
+	for az, err := range m.status.Errs {
+		if azName == "" || az == azName {
+			res.Errs[az] = err
+		}
+	}
+	return res
+}
+
+func (s *FederationStatus) clusterLinks(azName string) []*FederationLink {
+	res := make([]*FederationLink, 0)
+	for _, l := range s.Links {
+		if l.AzName == azName {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+// GetFederationStatus returns the last known state of the federation links, just on the cluster in azName if it's
+// given
+func GetFederationStatus(azName string) *FederationStatus {
+	return federation.get(azName)
+}
+
+// FederationPoll returns how often the federation links are polled
+func FederationPoll() time.Duration {
+	return *federationPoll
+}
+
+// ThisAz returns the AZ this instance is running in
+func ThisAz() string {
+	return thisAz
+}
+
+// IsFederationDown returns whether every link on this AZ's cluster from the upstreams in az has been down for long
+// enough to treat az as failed. If nothing is known about the links it isn't.
+func IsFederationDown(az string) bool {
+	return IsFederationDownTo(thisAz, az)
+}
+
+// IsFederationDownTo returns whether every link on the cluster in to from the upstreams in az has been down for long
+// enough that to doesn't bind az
+func IsFederationDownTo(to string, az string) bool {
+	if *federationDownAfter == 0 || az == to {
+		return false
+	}
+	return federationDown(federation.get(to).Links, az, time.Now(), *federationDownAfter)
+}
+
+func federationDown(links []*FederationLink, az string, now time.Time, downAfter time.Duration) bool {
+	found := false
+	for _, l := range links {
+		if l.UpstreamAz != az {
+			continue
+		}
+		if l.Status == LINK_RUNNING || now.Sub(l.Since) < downAfter {
+			return false
+		}
+		found = true
+	}
+	return found
+}
@@ -0,0 +1,274 @@
+package binding
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/dao"
+	"github.com/HailoOSS/binding-service/rabbit"
+)
+
+var (
+	gcInterval     = flag.Duration("binding_gc_interval", 10*time.Minute, "Interval between passes collecting orphaned queues on this AZ's cluster, 0 to disable")
+	gcGrace        = flag.Duration("binding_gc_grace", 30*time.Minute, "How long a queue has to be orphaned before it's collected")
+	gcDryRun       = flag.Bool("binding_gc_dry_run", true, "Only report the orphaned queues which would be collected")
+	gcDeleteQueues = flag.Bool("binding_gc_delete_queues", false, "Delete orphaned queues as well as removing their bindings")
+	gcProtected    = flag.String("binding_gc_protected", `^federation: ,^amq\.,^h2o\.deadletter,^h2o\.unroutable$`, "Comma separated regexps of queue names which are never collected")
+
+	orphans = &orphanTracker{load: dao.GetOrphans, save: dao.SetOrphans}
+)
+
+// what happened to an orphaned queue
+const (
+	GC_PENDING = "pending" // orphaned for less than the grace period
+	GC_COLLECT = "collect" // would be collected, in a dry run
+	GC_UNBOUND = "unbound" // its bindings were removed
+	GC_DELETED = "deleted" // its bindings were removed and it was deleted
+	GC_FAILED  = "failed"
+)
+
+// OrphanedQueue is a queue with no consumers which isn't the queue of any instance in discovery
+type OrphanedQueue struct {
+	Vhost     string
+	Name      string
+	Messages  int
+	FirstSeen time.Time // when it was first seen orphaned
+	Action    string
+	Err       error
+}
+
+// GCReport is the outcome of a pass collecting orphaned queues
+type GCReport struct {
+	Started time.Time
+	AzName  string
+	DryRun  bool
+	Queues  []*OrphanedQueue
+}
+
+// orphanTracker remembers when each queue was first seen orphaned. The times are kept in cassandra so every instance
+// reports the same ones and a new leader carries on with the grace periods.
+type orphanTracker struct {
+	load func(azName string) (map[string]time.Time, error)
+	save func(azName string, firstSeen map[string]time.Time, gone []string) error
+}
+
+// gcLoop collects orphaned queues until the context is done, only the leader runs it
+func gcLoop(ctx context.Context) {
+	if *gcInterval == 0 {
+		return
+	}
+	for {
+		report, err := CollectOrphanedQueues(ctx, *gcDryRun)
+		if err != nil {
+			log.Errorf("Error collecting orphaned queues %+v", err)
+		} else {
+			report.logSummary()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(*gcInterval):
+		}
+	}
+}
+
+// logSummary logs and records how many orphaned queues there were by action
+func (r *GCReport) logSummary() {
+	counts := make(map[string]int)
+	for _, q := range r.Queues {
+		counts[q.Action]++
+		gcQueues.Inc(q.Action)
+	}
+	log.Infof("Found %d orphaned queues on %s (dry run %v): %v", len(r.Queues), r.AzName, r.DryRun, counts)
+}
+
+// FindOrphanedQueues reports the orphaned queues on this AZ's cluster, when they were first seen orphaned and whether
+// they're pending or would be collected, without changing anything
+func FindOrphanedQueues(ctx context.Context) (*GCReport, error) {
+	report := &GCReport{Started: time.Now(), AzName: thisAz, DryRun: true}
+	queues, err := getOrphanedQueues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if report.Queues, err = orphans.peek(thisAz, queues, report.Started); err != nil {
+		return nil, err
+	}
+	for _, q := range report.Queues {
+		q.Action = gcAction(q, report.Started)
+	}
+	return report, nil
+}
+
+// CollectOrphanedQueues finds the orphaned queues on this AZ's cluster. Unless it's a dry run the ones which have
+// been orphaned for longer than the grace period have their bindings removed, and are deleted if that's enabled.
+func CollectOrphanedQueues(ctx context.Context, dryRun bool) (*GCReport, error) {
+	report := &GCReport{Started: time.Now(), AzName: thisAz, DryRun: dryRun}
+	queues, err := getOrphanedQueues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if report.Queues, err = orphans.track(thisAz, queues, report.Started); err != nil {
+		return nil, err
+	}
+
+	for _, q := range report.Queues {
+		if q.Action = gcAction(q, report.Started); q.Action == GC_PENDING || dryRun {
+			continue
+		}
+		q.Action, q.Err = collectQueue(ctx, q)
+		if q.Err != nil {
+			log.Errorf("Error collecting orphaned queue %s in vhost %s %+v", q.Name, q.Vhost, q.Err)
+		} else {
+			log.Infof("Orphaned queue %s in vhost %s with %d messages %s", q.Name, q.Vhost, q.Messages, q.Action)
+		}
+	}
+	return report, nil
+}
+
+// getOrphanedQueues returns the orphaned queues on this AZ's cluster
+func getOrphanedQueues(ctx context.Context) ([]*OrphanedQueue, error) {
+	protected, err := protectedQueues(*gcProtected)
+	if err != nil {
+		return nil, err
+	}
+	services, err := GetInstances()
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving instances %v", err)
+	}
+	if len(services) == 0 {
+		// more likely a problem with discovery than nothing running, and everything would be orphaned
+		return nil, fmt.Errorf("No instances in discovery, not collecting orphaned queues")
+	}
+	queues, err := getRabbitClient().GetQueues(ctx, LocalHost)
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving queues %v", err)
+	}
+
+	instances := make(map[string]bool, len(services))
+	for _, s := range services {
+		instances[s.Instance] = true
+	}
+	return findOrphans(queues, instances, protected), nil
+}
+
+// gcAction returns whether the queue is still pending or should be collected
+func gcAction(q *OrphanedQueue, now time.Time) string {
+	if now.Sub(q.FirstSeen) < *gcGrace {
+		return GC_PENDING
+	}
+	return GC_COLLECT
+}
+
+// findOrphans returns the queues in the managed vhosts with no consumers which aren't the queue of an instance
+func findOrphans(queues []*rabbit.Queue, instances map[string]bool, protected []*regexp.Regexp) []*OrphanedQueue {
+	res := make([]*OrphanedQueue, 0)
+	for _, q := range queues {
+		if q.Consumers > 0 || instances[q.Name] || !IsManagedVhost(q.Vhost) || isProtected(q.Name, protected) {
+			continue
+		}
+		res = append(res, &OrphanedQueue{Vhost: q.Vhost, Name: q.Name, Messages: q.Messages})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Vhost+"|"+res[i].Name < res[j].Vhost+"|"+res[j].Name })
+	return res
+}
+
+// track sets when each queue was first seen orphaned, recording the ones seen for the first time and forgetting the
+// ones which aren't orphaned any more
+func (t *orphanTracker) track(azName string, queues []*OrphanedQueue, now time.Time) ([]*OrphanedQueue, error) {
+	prev, err := t.load(azName)
+	if err != nil {
+		return nil, err
+	}
+	added := make(map[string]time.Time)
+	seen := make(map[string]bool, len(queues))
+	for _, q := range queues {
+		key := q.Vhost + "|" + q.Name
+		first, ok := prev[key]
+		if !ok {
+			first = now
+			added[key] = first
+		}
+		q.FirstSeen = first
+		seen[key] = true
+	}
+	gone := make([]string, 0)
+	for key := range prev {
+		if !seen[key] {
+			gone = append(gone, key)
+		}
+	}
+	if err := t.save(azName, added, gone); err != nil {
+		return nil, err
+	}
+	return queues, nil
+}
+
+// peek sets when each queue was first seen orphaned, or now if it hasn't been, without tracking anything
+func (t *orphanTracker) peek(azName string, queues []*OrphanedQueue, now time.Time) ([]*OrphanedQueue, error) {
+	prev, err := t.load(azName)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range queues {
+		first, ok := prev[q.Vhost+"|"+q.Name]
+		if !ok {
+			first = now
+		}
+		q.FirstSeen = first
+	}
+	return queues, nil
+}
+
+func protectedQueues(patterns string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0)
+	for _, p := range strings.Split(patterns, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid protected queue pattern %s %v", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func isProtected(name string, protected []*regexp.Regexp) bool {
+	for _, re := range protected {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectQueue removes the queue's bindings and deletes it if that's enabled
+func collectQueue(ctx context.Context, q *OrphanedQueue) (string, error) {
+	bindings, err := getRabbitClient().GetQueueBindings(ctx, LocalHost, q.Vhost, q.Name)
+	if err != nil {
+		return GC_FAILED, err
+	}
+	for _, b := range bindings {
+		if b.Source == "" {
+			// the default exchange binding can't be removed
+			continue
+		}
+		if err := getRabbitClient().DeleteBinding(ctx, LocalHost, b); err != nil && !rabbit.IsNotFound(err) {
+			return GC_FAILED, err
+		}
+	}
+	if !*gcDeleteQueues {
+		return GC_UNBOUND, nil
+	}
+	if err := getRabbitClient().DeleteQueue(ctx, LocalHost, q.Vhost, q.Name); err != nil && !rabbit.IsNotFound(err) {
+		return GC_FAILED, err
+	}
+	return GC_DELETED, nil
+}
@@ -0,0 +1,133 @@
+package binding
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/rabbit"
+	"github.com/HailoOSS/binding-service/util"
+)
+
+const (
+	MAX_SAMPLE_MESSAGES = 20
+	PURGE_TOKEN_WINDOW  = 5 * time.Minute // a purge token is valid for between one and two windows
+)
+
+var ErrInvalidPurgeToken = fmt.Errorf("Invalid or expired purge token")
+
+// QueueInspection is the state of a queue, its bindings and some of its messages
+type QueueInspection struct {
+	AzName   string
+	Host     string
+	Queue    *rabbit.Queue
+	Bindings []*domain.BindingDef
+	Messages []*rabbit.Message
+}
+
+// clusterHost returns the node calls to the cluster in azName should go to, this AZ's if it's empty
+func clusterHost(azName string) (string, string, error) {
+	if azName == "" {
+		azName = thisAz
+	}
+	hosts, err := util.GetRabbitHosts()
+	if err != nil {
+		return "", "", err
+	}
+	if _, ok := hosts[azName]; !ok {
+		return "", "", fmt.Errorf("No rabbit cluster for AZ %s", azName)
+	}
+	return azName, getRabbitClient().PickNode(hosts[azName]), nil
+}
+
+// resolveQueue returns the cluster and vhost of the queue, looking the vhost up if it isn't given
+func resolveQueue(ctx context.Context, azName string, vhost string, queue string) (string, string, string, error) {
+	azName, host, err := clusterHost(azName)
+	if err != nil {
+		return "", "", "", err
+	}
+	if vhost == "" {
+		if vhost, err = queueVhostOn(ctx, host, queue); err != nil {
+			return "", "", "", err
+		}
+	}
+	return azName, host, vhost, nil
+}
+
+// InspectQueue returns the queue on the cluster in azName with its bindings and up to samples messages from its head.
+// The messages are requeued. The vhost is looked up if it isn't given.
+func InspectQueue(ctx context.Context, azName string, vhost string, queue string, samples int) (*QueueInspection, error) {
+	azName, host, vhost, err := resolveQueue(ctx, azName, vhost, queue)
+	if err != nil {
+		return nil, err
+	}
+	res := &QueueInspection{AzName: azName, Host: host}
+	if res.Queue, err = getRabbitClient().GetQueue(ctx, host, vhost, queue); err != nil {
+		return nil, err
+	}
+	if res.Bindings, err = getRabbitClient().GetQueueBindings(ctx, host, vhost, queue); err != nil {
+		return nil, err
+	}
+	if samples > MAX_SAMPLE_MESSAGES {
+		samples = MAX_SAMPLE_MESSAGES
+	}
+	if samples > 0 {
+		if res.Messages, err = getRabbitClient().GetMessages(ctx, host, vhost, queue, samples); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// PurgeToken returns the token confirming the queue should be purged. It only depends on the queue and the time so
+// any instance can check it, it's there to stop accidents rather than for security.
+func PurgeToken(azName string, vhost string, queue string, now time.Time) string {
+	window := now.Unix() / int64(PURGE_TOKEN_WINDOW/time.Second)
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%d", azName, vhost, queue, window)))
+	return hex.EncodeToString(sum[:8])
+}
+
+func validPurgeToken(token string, azName string, vhost string, queue string, now time.Time) bool {
+	return token != "" && (token == PurgeToken(azName, vhost, queue, now) || token == PurgeToken(azName, vhost, queue, now.Add(-PURGE_TOKEN_WINDOW)))
+}
+
+// PurgeResult is the outcome of a request to purge a queue
+type PurgeResult struct {
+	AzName   string
+	Vhost    string
+	Purged   bool
+	Token    string // to confirm the purge with, when it wasn't purged
+	Messages int    // in the queue before it was purged
+}
+
+// PurgeQueue deletes all the messages in the queue on the cluster in azName if the token confirms it. Without a token
+// the queue is left alone and the token to confirm it with is returned.
+func PurgeQueue(ctx context.Context, azName string, vhost string, queue string, token string) (*PurgeResult, error) {
+	azName, host, vhost, err := resolveQueue(ctx, azName, vhost, queue)
+	if err != nil {
+		return nil, err
+	}
+	q, err := getRabbitClient().GetQueue(ctx, host, vhost, queue)
+	if err != nil {
+		return nil, err
+	}
+	res := &PurgeResult{AzName: azName, Vhost: vhost, Messages: q.Messages}
+	now := time.Now()
+	if token == "" {
+		res.Token = PurgeToken(azName, vhost, queue, now)
+		return res, nil
+	}
+	if !validPurgeToken(token, azName, vhost, queue, now) {
+		return nil, ErrInvalidPurgeToken
+	}
+	if err := getRabbitClient().PurgeQueue(ctx, host, vhost, queue); err != nil {
+		return nil, err
+	}
+	log.Infof("Purged queue %s in vhost %s on %s which had %d messages", queue, vhost, azName, q.Messages)
+	res.Purged = true
+	return res, nil
+}
@@ -0,0 +1,89 @@
+package binding
+
+import (
+	"context"
+	"fmt"
+	log "github.com/cihub/seelog"
+	"sync/atomic"
+	"time"
+
+	"github.com/HailoOSS/binding-service/dao"
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/platform/server"
+	"github.com/HailoOSS/service/sync"
+)
+
+const (
+	LEADER_ID      = "com.HailoOSS.kernel.binding.leader.%s"
+	LEADER_REFRESH = 30 * time.Second
+	LEADER_TTL     = 3 * LEADER_REFRESH // leader record expires if the leader stops refreshing it
+)
+
+var isLeader int32
+
+// IsLeader returns whether this instance is running the periodic reconciliation for its AZ
+func IsLeader() bool {
+	return atomic.LoadInt32(&isLeader) == 1
+}
+
+// GetLeader returns the leader for the AZ, defaulting to this AZ. Returns nil if no leader is known.
+func GetLeader(azName string) (*domain.Leader, error) {
+	if azName == "" {
+		azName = thisAz
+	}
+	return dao.GetLeader(azName)
+}
+
+// runLeaderElection competes to become the leader for this AZ. Only the leader runs the periodic rebinding and
+// tearing down, everyone else just serves requests. Leadership is rescinded if we lose our zookeeper session, at
+// which point we stop reconciling and rejoin the election.
+func runLeaderElection() {
+	for {
+		// blocks until we're elected
+		l := sync.RegionLeader(fmt.Sprintf(LEADER_ID, thisAz))
+		log.Infof("Elected leader for AZ %s", thisAz)
+		atomic.StoreInt32(&isLeader, 1)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go advertiseLeadership(ctx, &domain.Leader{InstanceId: server.InstanceID, Hostname: LocalHost, AzName: thisAz, Since: time.Now()})
+		go reconcileLoop(ctx)
+		go gcLoop(ctx)
+		go unroutableLoop(ctx)
+
+		<-l.Rescinded()
+		log.Warnf("Leadership for AZ %s rescinded, stopping reconciliation", thisAz)
+		atomic.StoreInt32(&isLeader, 0)
+		cancel()
+	}
+}
+
+// advertiseLeadership periodically records us as the leader until the context is done, then clears the record
+func advertiseLeadership(ctx context.Context, leader *domain.Leader) {
+	for {
+		if err := dao.SetLeader(leader, LEADER_TTL); err != nil {
+			log.Errorf("Error recording leader %+v", err)
+		}
+		select {
+		case <-ctx.Done():
+			// don't leave it claiming we're the leader until it expires
+			if err := dao.ClearLeader(leader.AzName, leader.InstanceId); err != nil {
+				log.Errorf("Error clearing leader %+v", err)
+			}
+			return
+		case <-time.After(LEADER_REFRESH):
+		}
+	}
+}
+
+// reconcileLoop sets up the local topology and runs rebind cycles until the context is done
+func reconcileLoop(ctx context.Context) {
+	for {
+		setupLocalTopology(ctx)
+		runRebindCycle(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(addJitterTo(rebindBackoff())):
+		}
+	}
+}
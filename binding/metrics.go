@@ -0,0 +1,35 @@
+package binding
+
+import (
+	"time"
+
+	"github.com/HailoOSS/binding-service/metrics"
+)
+
+var (
+	serviceOps        = metrics.NewCounterVec("binding_service_operations_total", "Service instance setups and teardowns by result", "op", "result")
+	serviceOpDuration = metrics.NewHistogramVec("binding_service_operation_duration_seconds", "Duration of service instance setups and teardowns", metrics.DefaultBuckets, "op")
+	bindingOps        = metrics.NewCounterVec("binding_cluster_operations_total", "Binding operations against each cluster while setting up services", "cluster", "result")
+	rebindCycles      = metrics.NewCounterVec("binding_rebind_cycles_total", "Rebind cycles by result", "result")
+	rebindDuration    = metrics.NewHistogramVec("binding_rebind_duration_seconds", "Duration of rebind cycles", []float64{1, 5, 10, 30, 60, 120, 300, 600})
+	discoveryTotal    = metrics.NewCounterVec("binding_cluster_discovery_total", "Cluster node discoveries by cluster and result", "cluster", "result")
+	gcQueues          = metrics.NewCounterVec("binding_gc_queues_total", "Orphaned queues found by background garbage collection passes by action", "action")
+)
+
+const (
+	OP_SETUP    = "setup"
+	OP_TEARDOWN = "teardown"
+)
+
+// observeServiceOp records the outcome of setting up or tearing down a service
+func observeServiceOp(op string, start time.Time, failed bool) {
+	serviceOpDuration.ObserveSince(start, op)
+	serviceOps.Inc(op, resultLabel(failed))
+}
+
+func resultLabel(failed bool) string {
+	if failed {
+		return "error"
+	}
+	return "ok"
+}
@@ -0,0 +1,84 @@
+package binding
+
+import (
+	"sort"
+	"sync"
+
+	log "github.com/cihub/seelog"
+)
+
+// ClusterResult is the outcome of binding operations against a single cluster
+type ClusterResult struct {
+	Succeeded int
+	Failed    int
+	Errors    []error
+}
+
+// RebindResult aggregates the outcome of binding operations per cluster (keyed by AZ name)
+type RebindResult struct {
+	sync.Mutex
+	Clusters  map[string]*ClusterResult
+	Instances int // number of service instances to set up
+	Completed int // number of service instances processed so far
+}
+
+func newRebindResult() *RebindResult {
+	return &RebindResult{Clusters: make(map[string]*ClusterResult)}
+}
+
+// record adds the outcome of a single operation against the cluster in azName to the result and metrics. Safe to
+// call on a nil result.
+func (r *RebindResult) record(azName string, err error) {
+	bindingOps.Inc(azName, resultLabel(err != nil))
+	if r == nil {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	cr, ok := r.Clusters[azName]
+	if !ok {
+		cr = &ClusterResult{}
+		r.Clusters[azName] = cr
+	}
+	if err != nil {
+		cr.Failed++
+		cr.Errors = append(cr.Errors, err)
+	} else {
+		cr.Succeeded++
+	}
+}
+
+func (r *RebindResult) logSummary() {
+	r.Lock()
+	defer r.Unlock()
+	azs := make([]string, 0, len(r.Clusters))
+	for az := range r.Clusters {
+		azs = append(azs, az)
+	}
+	sort.Strings(azs)
+	for _, az := range azs {
+		cr := r.Clusters[az]
+		if cr.Failed > 0 {
+			log.Errorf("Rebinding cluster %s: %d succeeded, %d failed, first error %v", az, cr.Succeeded, cr.Failed, cr.Errors[0])
+		} else {
+			log.Debugf("Rebinding cluster %s: %d succeeded", az, cr.Succeeded)
+		}
+	}
+}
+
+// instancesDone marks n service instances as processed. Safe to call on a nil result.
+func (r *RebindResult) instancesDone(n int) {
+	if r == nil {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.Completed += n
+}
+
+// Progress returns the number of instances processed and the number to process
+func (r *RebindResult) Progress() (int, int) {
+	r.Lock()
+	defer r.Unlock()
+	return r.Completed, r.Instances
+}
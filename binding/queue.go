@@ -0,0 +1,351 @@
+package binding
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/raven"
+	log "github.com/cihub/seelog"
+)
+
+var (
+	rebindWorkers       = flag.Int("binding_rebind_workers", 16, "Number of workers processing queued binding work")
+	workTimeout         = flag.Duration("binding_work_timeout", time.Minute, "Maximum time to spend processing a single piece of binding work")
+	workMaxRetries      = flag.Int("binding_work_max_retries", 5, "Number of times failed binding work is retried")
+	workRetryBackoff    = flag.Duration("binding_work_retry_backoff", time.Second, "Initial delay before retrying failed binding work, doubles with each attempt")
+	workMaxRetryBackoff = flag.Duration("binding_work_max_retry_backoff", time.Minute, "Maximum delay before retrying failed binding work")
+
+	work = newWorkQueue()
+)
+
+// workItem is the outstanding work for a service in an AZ. Bursts of discovery events for the same service are
+// coalesced into a single item so the remote bindings are only set up once.
+type workItem struct {
+	service  string
+	azName   string
+	up       map[string]*domain.Service // instances to set up, by instance id
+	down     map[string]bool            // queues to tear down
+	ctx      context.Context            // set when the work only came from a resync, so it's aborted with the resync
+	res      *RebindResult              // where to record the outcome, if anywhere
+	notify   []chan struct{}            // closed once the work has been attempted
+	attempts int
+}
+
+func newWorkItem(service, azName string) *workItem {
+	return &workItem{service: service, azName: azName, up: make(map[string]*domain.Service), down: make(map[string]bool)}
+}
+
+func (w *workItem) key() string {
+	return fmt.Sprintf(LOCK_STRING, w.service, w.azName)
+}
+
+// merge folds newer work for the same service into w, the latest event for an instance wins
+func (w *workItem) merge(o *workItem) {
+	for id, s := range o.up {
+		w.up[id] = s
+		delete(w.down, id)
+	}
+	for id := range o.down {
+		w.down[id] = true
+		delete(w.up, id)
+	}
+	if w.ctx != nil && o.ctx != nil {
+		w.ctx = o.ctx
+	} else {
+		// an event was merged in, it mustn't be aborted with a resync
+		w.ctx = nil
+	}
+	if w.res == nil {
+		w.res = o.res
+	}
+	w.notify = append(w.notify, o.notify...)
+	if o.attempts > w.attempts {
+		w.attempts = o.attempts
+	}
+}
+
+// dedup removes anything from w which is already being done by the in flight work, returning how many instances
+// were removed
+func (w *workItem) dedup(inFlight *workItem) int {
+	n := 0
+	for id, s := range w.up {
+		if f, ok := inFlight.up[id]; ok && reflect.DeepEqual(f, s) {
+			delete(w.up, id)
+			n++
+		}
+	}
+	for id := range w.down {
+		if inFlight.down[id] {
+			delete(w.down, id)
+		}
+	}
+	return n
+}
+
+func (w *workItem) empty() bool {
+	return len(w.up) == 0 && len(w.down) == 0
+}
+
+// workQueue holds binding work keyed by service and AZ. Work for a key is never processed concurrently, anything
+// queued for a key while it's in flight is held back until it completes.
+type workQueue struct {
+	sync.Mutex
+	cond     *sync.Cond
+	queue    []string
+	pending  map[string]*workItem
+	inFlight map[string]*workItem
+}
+
+func newWorkQueue() *workQueue {
+	q := &workQueue{pending: make(map[string]*workItem), inFlight: make(map[string]*workItem)}
+	q.cond = sync.NewCond(q)
+	return q
+}
+
+func (q *workQueue) add(w *workItem) {
+	q.Lock()
+	defer q.Unlock()
+	key := w.key()
+	f, inFlight := q.inFlight[key]
+	if inFlight {
+		n := w.dedup(f)
+		w.res.instancesDone(n)
+		if w.empty() {
+			// everything is already being done, just wait for it
+			f.notify = append(f.notify, w.notify...)
+			return
+		}
+	}
+	if p, ok := q.pending[key]; ok {
+		p.merge(w)
+		return
+	}
+	q.pending[key] = w
+	if !inFlight {
+		q.queue = append(q.queue, key)
+		q.cond.Signal()
+	}
+}
+
+// get blocks until there's work to do, marking it as in flight
+func (q *workQueue) get() *workItem {
+	q.Lock()
+	defer q.Unlock()
+	for len(q.queue) == 0 {
+		q.cond.Wait()
+	}
+	key := q.queue[0]
+	q.queue = q.queue[1:]
+	w := q.pending[key]
+	delete(q.pending, key)
+	q.inFlight[key] = w
+	return w
+}
+
+// done marks the work as complete, scheduling a retry if it failed
+func (q *workQueue) done(w *workItem, errObj errors.Error) {
+	q.Lock()
+	key := w.key()
+	delete(q.inFlight, key)
+	notify := w.notify
+	w.notify = nil
+	if _, ok := q.pending[key]; ok {
+		q.queue = append(q.queue, key)
+		q.cond.Signal()
+	}
+	q.Unlock()
+
+	for _, c := range notify {
+		close(c)
+	}
+
+	if errObj == nil {
+		return
+	}
+	if w.ctx != nil && w.ctx.Err() != nil {
+		// the resync was aborted, the next one sets the service up again
+		log.Debugf("Not retrying binding work for %s in %s, its resync was aborted: %s", w.service, w.azName, errObj.Description())
+		return
+	}
+	if w.attempts >= *workMaxRetries {
+		log.Errorf("Giving up on binding work for %s in %s after %d attempts: %s", w.service, w.azName, w.attempts+1, errObj.Description())
+		return
+	}
+	retry := newWorkItem(w.service, w.azName)
+	retry.up = w.up
+	retry.down = w.down
+	retry.ctx = w.ctx
+	retry.res = w.res
+	retry.attempts = w.attempts + 1
+	backoff := retryBackoff(w.attempts)
+	log.Debugf("Retrying binding work for %s in %s in %v: %s", w.service, w.azName, backoff, errObj.Description())
+	time.AfterFunc(backoff, func() { q.add(retry) })
+}
+
+// run starts the workers processing the queue
+func (q *workQueue) run(workers int, process func(*workItem) errors.Error) {
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for {
+				w := q.get()
+				q.done(w, process(w))
+			}
+		}()
+	}
+}
+
+func retryBackoff(attempts int) time.Duration {
+	d := *workRetryBackoff
+	for i := 0; i < attempts && d < *workMaxRetryBackoff; i++ {
+		d *= 2
+	}
+	if d > *workMaxRetryBackoff {
+		d = *workMaxRetryBackoff
+	}
+	return addJitterTo(d)
+}
+
+// EnqueueServiceUp queues the service instance to be set up
+func EnqueueServiceUp(s *domain.Service) {
+	if thisAz != s.AzName {
+		return // not in the corresponding AZ
+	}
+	w := newWorkItem(s.Service, s.AzName)
+	w.up[s.Instance] = s
+	work.add(w)
+}
+
+// EnqueueServiceDown queues the service instance's queue to be torn down
+func EnqueueServiceDown(service string, queue string, azName string) {
+	if thisAz != azName {
+		return // not in the corresponding AZ
+	}
+	w := newWorkItem(service, azName)
+	w.down[queue] = true
+	work.add(w)
+}
+
+// resync queues work for all the service instances, recording the outcome in res, and waits for it to be attempted
+func resync(ctx context.Context, services []*domain.Service, res *RebindResult) {
+	items := make(map[string]*workItem)
+	for _, s := range services {
+		w, ok := items[s.Service]
+		if !ok {
+			w = newWorkItem(s.Service, s.AzName)
+			w.ctx = ctx
+			w.res = res
+			items[s.Service] = w
+		}
+		w.up[s.Instance] = s
+	}
+
+	res.Lock()
+	res.Instances += len(services)
+	res.Unlock()
+
+	waiting := make([]chan struct{}, 0, len(items))
+	for _, w := range items {
+		c := make(chan struct{})
+		w.notify = []chan struct{}{c}
+		waiting = append(waiting, c)
+		work.add(w)
+	}
+	for i, c := range waiting {
+		select {
+		case <-c:
+		case <-ctx.Done():
+			log.Errorf("Rebinding aborted waiting for %d services: %v", len(waiting)-i, ctx.Err())
+			return
+		}
+	}
+}
+
+// processWork tears down and sets up the bindings for the work's service instances
+func processWork(w *workItem) errors.Error {
+	parent := w.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, *workTimeout)
+	defer cancel()
+	op := OP_SETUP
+	if len(w.up) == 0 {
+		op = OP_TEARDOWN
+	}
+	start := time.Now()
+	var firstErr errors.Error
+	defer func() { observeServiceOp(op, start, firstErr != nil) }()
+
+	log.Debugf("Processing binding work for %s in %s, %d up %d down", w.service, w.azName, len(w.up), len(w.down))
+	lock, err := getLock(w.service, w.azName)
+	defer lock.Unlock()
+	if err != nil {
+		log.Errorf("Failed to acquire lock to process binding work %+v", err)
+		firstErr = errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", err.Error())
+		return firstErr
+	}
+
+	for queue := range w.down {
+		// remove binding - normally auto removed since queue should die BUT if service that's down still has it's connection but not responding then we need to remove binding
+		for _, vhost := range Vhosts() {
+			DeleteLocalServiceBindings(ctx, LocalHost, vhost, raven.EXCHANGE, queue)
+		}
+		if errObj := teardownDirect(ctx, w.service, queue, w.azName); errObj != nil {
+			log.Errorf("Error while attempting to tear down direct bindings to %s %s", queue, errObj.Description())
+			if firstErr == nil {
+				firstErr = errObj
+			}
+		}
+	}
+
+	first := make(map[string]*domain.Service) // first instance in each vhost
+	for _, s := range w.up {
+		vs, err := withVhost(ctx, s)
+		var errObj errors.Error
+		if err != nil {
+			errObj = errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", fmt.Sprintf("Error while finding the vhost of %v. %v", s.Instance, err))
+		} else {
+			if _, ok := first[vs.Vhost]; !ok {
+				first[vs.Vhost] = vs
+			}
+			var rules []*domain.Rule
+			rules, errObj = getRules(vs)
+			if errObj == nil {
+				errObj = setupLocal(ctx, vs, rules, w.res)
+			}
+		}
+		if errObj != nil {
+			log.Errorf("Error while attempting to setup service %#v %s", s, errObj.Description())
+			if firstErr == nil {
+				firstErr = errObj
+			}
+		}
+	}
+	if w.attempts == 0 {
+		// retries are already counted
+		w.res.instancesDone(len(w.up))
+	}
+
+	if len(first) > 0 {
+		// the remote bindings are the same for every instance in a vhost so only need doing once per vhost
+		for _, s := range first {
+			if errObj := setupRemote(ctx, s, w.res); errObj != nil && firstErr == nil {
+				firstErr = errObj
+			}
+		}
+	} else if len(w.down) > 0 {
+		if errObj := teardownRemote(ctx, w.service, w.azName); errObj != nil && firstErr == nil {
+			firstErr = errObj
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,229 @@
+package binding
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/util"
+	"github.com/HailoOSS/platform/errors"
+	hsync "github.com/HailoOSS/service/sync"
+)
+
+func upItem(service, instance string) *workItem {
+	w := newWorkItem(service, "eu-west-1a")
+	w.up[instance] = &domain.Service{Service: service, Instance: instance, AzName: "eu-west-1a"}
+	return w
+}
+
+func TestWorkQueueCoalesces(t *testing.T) {
+	q := newWorkQueue()
+	q.add(upItem("com.HailoOSS.service.foo", "foo-1"))
+	q.add(upItem("com.HailoOSS.service.foo", "foo-2"))
+	q.add(upItem("com.HailoOSS.service.bar", "bar-1"))
+	if len(q.queue) != 2 {
+		t.Fatal("Work for the same service should be coalesced ", q.queue)
+	}
+	w := q.get()
+	if w.service != "com.HailoOSS.service.foo" || len(w.up) != 2 {
+		t.Errorf("Unexpected work %+v", w)
+	}
+
+	down := newWorkItem("com.HailoOSS.service.foo", "eu-west-1a")
+	down.down["foo-1"] = true
+	q.add(down)
+	if len(q.queue) != 1 {
+		t.Error("Work for an in flight service shouldn't be queued until it's done ", q.queue)
+	}
+	q.done(w, nil)
+	if len(q.queue) != 2 {
+		t.Error("Held back work should be queued once the in flight work is done ", q.queue)
+	}
+}
+
+func TestWorkQueueDedupsInFlight(t *testing.T) {
+	q := newWorkQueue()
+	q.add(upItem("com.HailoOSS.service.foo", "foo-1"))
+	w := q.get()
+
+	dup := upItem("com.HailoOSS.service.foo", "foo-1")
+	c := make(chan struct{})
+	dup.notify = []chan struct{}{c}
+	q.add(dup)
+	if len(q.pending) != 0 {
+		t.Error("Work already in flight should be dropped ", q.pending)
+	}
+	q.done(w, nil)
+	select {
+	case <-c:
+	default:
+		t.Error("Deduplicated work should be notified when the in flight work is done")
+	}
+}
+
+func TestWorkItemMerge(t *testing.T) {
+	w := upItem("com.HailoOSS.service.foo", "foo-1")
+	down := newWorkItem("com.HailoOSS.service.foo", "eu-west-1a")
+	down.down["foo-1"] = true
+	w.merge(down)
+	if len(w.up) != 0 || !w.down["foo-1"] {
+		t.Errorf("Later down event should win %+v", w)
+	}
+	w.merge(upItem("com.HailoOSS.service.foo", "foo-1"))
+	if len(w.down) != 0 || w.up["foo-1"] == nil {
+		t.Errorf("Later up event should win %+v", w)
+	}
+}
+
+// fakeBroker answers management API GETs ending with one of its paths and records the bindings deleted
+type fakeBroker struct {
+	sync.Mutex
+	*httptest.Server
+	responses map[string]string
+	deleted   []string
+}
+
+func newFakeBroker(responses map[string]string) *fakeBroker {
+	b := &fakeBroker{responses: responses}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.Lock()
+		defer b.Unlock()
+		if r.Method == "DELETE" {
+			b.deleted = append(b.deleted, r.RequestURI)
+			return
+		}
+		for path, rsp := range b.responses {
+			if strings.HasSuffix(r.RequestURI, path) {
+				w.Write([]byte(rsp))
+				return
+			}
+		}
+		w.Write([]byte("[]"))
+	}))
+	return b
+}
+
+type noLock struct{}
+
+func (noLock) Unlock() {}
+
+// useFakeClusters points this AZ, eu-west-1a, at the local broker and eu-west-1b at the remote one, returning a
+// function which restores them
+func useFakeClusters(t *testing.T, local *fakeBroker, remote *fakeBroker) func() {
+	// the clusters need different hosts as ports are ignored when matching a host to its cluster
+	localHost := strings.TrimPrefix(local.URL, "http://")
+	remoteHost := strings.Replace(strings.TrimPrefix(remote.URL, "http://"), "127.0.0.1", "localhost", 1)
+	dir, err := ioutil.TempDir("", "binding")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostsFile := filepath.Join(dir, "rabbithosts")
+	config := `{"clusters":[{"az":"eu-west-1a","nodes":["` + localHost + `"]},{"az":"eu-west-1b","nodes":["` + remoteHost + `"]}]}`
+	if err := ioutil.WriteFile(hostsFile, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	prevFile := flag.Lookup("rabbit_hosts_file").Value.String()
+	flag.Set("rabbit_hosts_file", hostsFile)
+	if err := util.ReloadRabbitHosts(); err != nil {
+		t.Fatal(err)
+	}
+	prevHost, prevAz, prevLock := LocalHost, thisAz, regionLock
+	LocalHost, thisAz = localHost, "eu-west-1a"
+	regionLock = func([]byte) (hsync.Lock, error) { return noLock{}, nil }
+	return func() {
+		LocalHost, thisAz, regionLock = prevHost, prevAz, prevLock
+		flag.Set("rabbit_hosts_file", prevFile)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestServiceDownTearsDownDirectBindings(t *testing.T) {
+	local := newFakeBroker(map[string]string{
+		// another instance is still bound so the service's bindings on other clusters stay
+		"/exchanges/%2F/h2o/bindings/source": `[{"source":"h2o","vhost":"/","destination":"foo-2","destination_type":"queue","arguments":{"service":"com.HailoOSS.foo"}}]`,
+		"/bindings/%2F/e/h2o.direct/q/foo-1": `[{"source":"h2o.direct","vhost":"/","destination":"foo-1","destination_type":"queue","routing_key":"foo-1","properties_key":"foo-1"},` +
+			`{"source":"h2o.direct","vhost":"/","destination":"foo-1","destination_type":"queue","routing_key":"com.HailoOSS.foo","properties_key":"com.HailoOSS.foo"}]`,
+	})
+	defer local.Close()
+	remote := newFakeBroker(map[string]string{
+		"/bindings/%2F/e/h2o.direct/e/h2o.direct.eu-west-1a": `[{"source":"h2o.direct","vhost":"/","destination":"h2o.direct.eu-west-1a","destination_type":"exchange","routing_key":"foo-1","properties_key":"foo-1"},` +
+			`{"source":"h2o.direct","vhost":"/","destination":"h2o.direct.eu-west-1a","destination_type":"exchange","routing_key":"com.HailoOSS.foo","properties_key":"com.HailoOSS.foo"}]`,
+	})
+	defer remote.Close()
+
+	defer useFakeClusters(t, local, remote)()
+
+	EnqueueServiceDown("com.HailoOSS.foo", "foo-1", "eu-west-1a")
+	w := work.get()
+	errObj := processWork(w)
+	work.done(w, nil)
+	if errObj != nil {
+		t.Fatal("Tearing down should succeed ", errObj.Description())
+	}
+
+	if len(local.deleted) != 2 || !strings.HasSuffix(local.deleted[0], "/e/h2o.direct/q/foo-1/foo-1") || !strings.HasSuffix(local.deleted[1], "/e/h2o.direct/q/foo-1/com.HailoOSS.foo") {
+		t.Error("Instance's direct bindings on its cluster should be deleted ", local.deleted)
+	}
+	if len(remote.deleted) != 1 || !strings.HasSuffix(remote.deleted[0], "/e/h2o.direct/e/h2o.direct.eu-west-1a/foo-1") {
+		t.Error("Only the instance's direct binding on the other cluster should be deleted ", remote.deleted)
+	}
+}
+
+func TestSetupRemovesUnadvertisedTopicBindings(t *testing.T) {
+	local := newFakeBroker(map[string]string{
+		"/bindings/%2F/e/h2o.topic/q/foo-1": `[{"source":"h2o.topic","vhost":"/","destination":"foo-1","destination_type":"queue","routing_key":"old.topic","properties_key":"old.topic"},` +
+			`{"source":"h2o.topic","vhost":"/","destination":"foo-1","destination_type":"queue","routing_key":"new.topic","properties_key":"new.topic"}]`,
+	})
+	defer local.Close()
+	remote := newFakeBroker(nil)
+	defer remote.Close()
+	defer useFakeClusters(t, local, remote)()
+
+	// the bindings are compared with what's advertised, so this works on any node and after a restart
+	s := &domain.Service{Service: "com.HailoOSS.foo", Instance: "foo-1", AzName: "eu-west-1a", Vhost: "/", Subscriptions: []string{"new.topic"}}
+	rules := []*domain.Rule{{Service: s.Service, Weight: 100}}
+	if errObj := setupLocal(context.Background(), s, rules, nil); errObj != nil {
+		t.Fatal(errObj.Description())
+	}
+	if len(local.deleted) != 1 || !strings.HasSuffix(local.deleted[0], "/e/h2o.topic/q/foo-1/old.topic") {
+		t.Error("Only the topic which isn't advertised should be unsubscribed ", local.deleted)
+	}
+}
+
+func TestWorkQueueRetries(t *testing.T) {
+	prev := *workRetryBackoff
+	*workRetryBackoff = time.Millisecond
+	defer func() { *workRetryBackoff = prev }()
+	failed := errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", "failed")
+
+	q := newWorkQueue()
+	ctx, cancel := context.WithCancel(context.Background())
+	res := newRebindResult()
+	w := upItem("com.HailoOSS.service.foo", "foo-1")
+	w.ctx, w.res = ctx, res
+	q.add(w)
+	q.done(q.get(), failed)
+	time.Sleep(50 * time.Millisecond)
+	retry := q.get()
+	if retry.ctx != ctx || retry.res != res || retry.attempts != 1 {
+		t.Errorf("Retry should keep the resync's context and result %+v", retry)
+	}
+
+	cancel()
+	q.done(retry, failed)
+	time.Sleep(50 * time.Millisecond)
+	q.Lock()
+	defer q.Unlock()
+	if len(q.pending) != 0 {
+		t.Error("Work from an aborted resync shouldn't be retried ", q.pending)
+	}
+}
@@ -0,0 +1,54 @@
+package binding
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/rabbit"
+)
+
+var queuePolicyPriority = flag.Int("binding_queue_policy_priority", 10, "Priority of the policies set by queue policy rules, rabbit applies one policy per queue so it has to beat any policy for every queue")
+
+const QUEUE_POLICY = "binding-queue-%s" // name of the policy for a service's instance queues
+
+// instanceQueuePattern matches the queues of the service's instances, which are named server-<service>-<id>
+func instanceQueuePattern(service string) string {
+	return "^server-" + regexp.QuoteMeta(service) + "-[^.]+$"
+}
+
+func queuePolicy(rule *domain.QueuePolicyRule) *rabbit.Policy {
+	return &rabbit.Policy{
+		Name:       fmt.Sprintf(QUEUE_POLICY, rule.Service),
+		Pattern:    instanceQueuePattern(rule.Service),
+		ApplyTo:    "queues",
+		Definition: rule.GetDefinition(DEADLETTER_EXCHANGE),
+		Priority:   *queuePolicyPriority,
+	}
+}
+
+// queuePolicies returns the policies for the rules which apply to the vhost keyed by name. A rule for the vhost wins
+// over a service's rule for every vhost.
+func queuePolicies(rules []*domain.QueuePolicyRule, vhost string) map[string]*rabbit.Policy {
+	byService := make(map[string]*domain.QueuePolicyRule)
+	for _, r := range rules {
+		if !r.IsApplicable(vhost) {
+			continue
+		}
+		if existing, ok := byService[r.Service]; !ok || existing.Vhost == "" {
+			byService[r.Service] = r
+		}
+	}
+	res := make(map[string]*rabbit.Policy, len(byService))
+	for _, r := range byService {
+		p := queuePolicy(r)
+		res[p.Name] = p
+	}
+	return res
+}
+
+func isQueuePolicy(name string) bool {
+	return strings.HasPrefix(name, fmt.Sprintf(QUEUE_POLICY, ""))
+}
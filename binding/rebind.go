@@ -0,0 +1,133 @@
+package binding
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+)
+
+var (
+	rebindInterval    = flag.Duration("binding_rebind_interval", REBIND_INTERVAL, "Interval between rebind cycles")
+	rebindTimeout     = flag.Duration("binding_rebind_timeout", REBIND_TIMEOUT, "Rebind cycles taking longer than this are aborted")
+	rebindMaxBackoff  = flag.Duration("binding_rebind_max_backoff", 15*time.Minute, "Maximum interval between rebind cycles when backing off after overruns")
+	rebindMaxOverruns = flag.Int("binding_rebind_max_overruns", 5, "Exit the process after this many consecutive rebind overruns, 0 to never exit")
+
+	status = &rebindStatus{}
+)
+
+// rebindStatus records the outcome of the most recent rebind cycles
+type rebindStatus struct {
+	sync.RWMutex
+	lastStart    time.Time
+	lastDuration time.Duration
+	lastResult   *RebindResult
+	lastErr      error
+	overruns     int // consecutive overruns
+}
+
+// RebindStatus is a snapshot of the most recent rebind cycle
+type RebindStatus struct {
+	LastStart    time.Time
+	LastDuration time.Duration
+	Completed    int // instances processed in the last cycle
+	Instances    int // instances to process in the last cycle
+	Err          error
+	Overruns     int // consecutive cycles that have overrun
+}
+
+// GetRebindStatus returns a snapshot of the most recent rebind cycle
+func GetRebindStatus() RebindStatus {
+	status.RLock()
+	defer status.RUnlock()
+	rs := RebindStatus{
+		LastStart:    status.lastStart,
+		LastDuration: status.lastDuration,
+		Err:          status.lastErr,
+		Overruns:     status.overruns,
+	}
+	if status.lastResult != nil {
+		rs.Completed, rs.Instances = status.lastResult.Progress()
+	}
+	return rs
+}
+
+// runRebindCycle rebinds everything, aborting outstanding work if it takes longer than the rebind timeout or the
+// parent context is done
+func runRebindCycle(parent context.Context) {
+	ctx, cancel := context.WithTimeout(parent, *rebindTimeout)
+	defer cancel()
+
+	start := time.Now()
+	res := newRebindResult()
+	completed := make(chan struct{})
+	go func() {
+		rebindAll(ctx, res)
+		close(completed)
+	}()
+
+	var err error
+	select {
+	case <-completed:
+	case <-ctx.Done():
+		// give in flight calls a moment to notice the cancellation so the progress we record is accurate
+		select {
+		case <-completed:
+		case <-time.After(time.Second):
+		}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		done, total := res.Progress()
+		err = fmt.Errorf("Failed to complete rebinding within timeout of %v, %d of %d service instances processed", *rebindTimeout, done, total)
+		log.Error(err)
+	}
+
+	rebindDuration.ObserveSince(start)
+	rebindCycles.Inc(rebindResultLabel(err))
+
+	status.Lock()
+	status.lastStart = start
+	status.lastDuration = time.Since(start)
+	status.lastResult = res
+	status.lastErr = err
+	if err != nil {
+		status.overruns++
+	} else {
+		status.overruns = 0
+	}
+	overruns := status.overruns
+	status.Unlock()
+
+	if *rebindMaxOverruns > 0 && overruns >= *rebindMaxOverruns {
+		log.Criticalf("Rebinding has overrun %d consecutive times, bailing completely to setup fresh", overruns)
+		log.Flush()
+		os.Exit(1)
+	}
+}
+
+func rebindResultLabel(err error) string {
+	if err != nil {
+		return "overrun"
+	}
+	return "ok"
+}
+
+// rebindBackoff returns the interval to wait before the next cycle, doubling for each consecutive overrun
+func rebindBackoff() time.Duration {
+	status.RLock()
+	overruns := status.overruns
+	status.RUnlock()
+
+	d := *rebindInterval
+	for i := 0; i < overruns && d < *rebindMaxBackoff; i++ {
+		d *= 2
+	}
+	if overruns > 0 && d > *rebindMaxBackoff {
+		d = *rebindMaxBackoff
+	}
+	return d
+}
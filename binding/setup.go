@@ -1,9 +1,9 @@
 package binding
 
 import (
+	"context"
 	"fmt"
 	log "github.com/cihub/seelog"
-	"net/http"
 	"time"
 
 	"github.com/HailoOSS/binding-service/dao"
@@ -18,26 +18,31 @@ import (
 
 var (
 	localServices map[string]bool = map[string]bool{"com.HailoOSS.kernel.binding": true} // services which shouldn't cross AZs
+	regionLock                    = sync.RegionLock                                       // replaced in tests
 )
 
 const (
 	REBIND_INTERVAL = 3 * time.Minute
-	REBIND_TIMEOUT  = 2 * time.Minute // after this time, we treat rebinding as failed, and bail out compeltely
+	REBIND_TIMEOUT  = 2 * time.Minute // after this time, we treat rebinding as failed and abort the cycle
 	LOCK_STRING     = "%s%s"
 )
 
+// IsLocalService returns whether the service is only bound in its own AZ
+func IsLocalService(service string) bool {
+	return localServices[service]
+}
+
 func PostConnectHandler() {
 	// register serviceup topic listener
-	httpClient := http.Client{}
 	subTopic := "com.HailoOSS.kernel.discovery.serviceup"
-	err := CreateTopicBindingE2Q(&httpClient, LocalHost+":"+DefaultRabbitPort, raven.TOPIC_EXCHANGE, server.InstanceID, subTopic)
+	err := CreateTopicBindingE2Q(context.Background(), LocalHost, localVhost(), raven.TOPIC_EXCHANGE, server.InstanceID, subTopic)
 	if err != nil {
 		log.Error("Failed to subscribe to ", subTopic, err)
 		panic(err)
 	}
 	log.Debug("Subscribed to ", subTopic)
 	subTopic = "com.HailoOSS.kernel.discovery.servicedown"
-	err = CreateTopicBindingE2Q(&httpClient, LocalHost+":"+DefaultRabbitPort, raven.TOPIC_EXCHANGE, server.InstanceID, subTopic)
+	err = CreateTopicBindingE2Q(context.Background(), LocalHost, localVhost(), raven.TOPIC_EXCHANGE, server.InstanceID, subTopic)
 	if err != nil {
 		log.Error("Failed to subscribe to ", subTopic, err)
 		panic(err)
@@ -46,52 +51,48 @@ func PostConnectHandler() {
 
 }
 
-// For rebinding, our responsibility is to make sure the bindings for our local services are correct across all clusters
-func rebindAll(httpClient *http.Client) {
+// For rebinding, our responsibility is to make sure the bindings for our local services are correct across all clusters.
+// Progress is recorded in res so that it's still available if the context is cancelled part way through.
+func rebindAll(ctx context.Context, res *RebindResult) {
 	log.Debug("Rebinding all service instances")
 
-	request, err := server.ScopedRequest(
-		"com.HailoOSS.kernel.discovery",
-		"instances",
-		&instances.Request{},
-	)
+	inst, err := GetInstances()
 	if err != nil {
 		log.Error(err)
 		return
 	}
 
-	response := &instances.Response{}
-
-	if err := client.Req(request, response); err != nil {
-		log.Error(err)
-		return
-	}
-
 	remoteRunning := make(map[string]*domain.Service)
-	inst := response.GetInstances()
-	for _, i := range inst {
-		s := domain.ServiceFromInstancesProto(i)
-		if i.GetAzName() == thisAz {
-			// Set up this service instance on this cluster
-			if err := SetupService(s); err != nil {
-				log.Errorf("Error while attempting to setup service %#v %s", s, err.Description())
-			}
+	remoteInstances := make(map[string]bool)
+	local := make([]*domain.Service, 0)
+	for _, s := range inst {
+		if s.AzName == thisAz {
+			local = append(local, s)
 		} else {
-			remoteRunning[i.GetAzName()+i.GetServiceName()] = s
+			remoteRunning[s.AzName+s.Service] = s
+			remoteInstances[s.AzName+s.Instance] = true
 		}
 	}
+	// Set up the service instances on this cluster
+	resync(ctx, local, res)
+	res.logSummary()
+	if ctx.Err() != nil {
+		// don't tear anything down based on a partial run
+		return
+	}
 	log.Debug("Rebinding all service instances complete")
 	if isRbFailedOver {
 		// make sure we teardown everything that is pointing to the old AZ which is now down
-		teardownRemotesForAZ(httpClient, thisAz)
+		teardownRemotesForAZ(ctx, thisAz)
 	} else {
 		// clean up this cluster
-		teardownMissing(thisAz, remoteRunning)
+		teardownMissing(ctx, thisAz, remoteRunning, remoteInstances)
+		teardownRemotesWithoutFederation(ctx)
 	}
 }
 
 // Tear down all bindings which point to this AZ - Use in failover scenario
-func teardownRemotesForAZ(httpClient *http.Client, az string) {
+func teardownRemotesForAZ(ctx context.Context, az string) {
 	log.Debugf("Tearing down remotes for AZ %s", az)
 	hosts, err := getRabbitClusterHosts()
 	if err != nil {
@@ -102,29 +103,68 @@ func teardownRemotesForAZ(httpClient *http.Client, az string) {
 		if host.AzName == thisAz {
 			continue
 		}
-		hostPort := host.Host + ":" + DefaultRabbitPort
-		bindings, err := GetAllExchangeBindings(httpClient, hostPort, az)
-		if err != nil {
+		if err := teardownRemotesOnHost(ctx, host.Host, az); err != nil {
 			log.Debugf("Error getting all exchange bindings, %+v", err)
 			return
 		}
+	}
+	log.Debugf("Tearing down remotes for AZ %s complete", az)
+
+}
+
+// Tear down the bindings on the host which point to the AZ
+func teardownRemotesOnHost(ctx context.Context, host string, az string) error {
+	for _, vhost := range Vhosts() {
+		bindings, err := GetAllExchangeBindings(ctx, host, vhost, az)
+		if err != nil {
+			return err
+		}
 		for _, b := range bindings {
-			DeleteBinding(httpClient, hostPort, b)
+			getRabbitClient().DeleteBinding(ctx, host, b)
+		}
+		if err := DeleteRemoteDirectBindings(ctx, host, vhost, "", az); err != nil {
+			return err
 		}
-
 	}
-	log.Debugf("Tearing down remotes for AZ %s complete", az)
+	return nil
+}
 
+// Tear down the bindings to this AZ on the clusters it can't receive federated messages from, they would only pile up
+// there. They're set up again by rebinding once federation recovers.
+func teardownRemotesWithoutFederation(ctx context.Context) {
+	hosts, err := getRabbitClusterHosts()
+	if err != nil {
+		log.Errorf("Error while retrieving hostnames, %+v", err)
+		return
+	}
+	for _, host := range hosts {
+		if !IsFederationDown(host.AzName) {
+			continue
+		}
+		log.Warnf("Federation from %s is down, tearing down its bindings to %s", host.AzName, thisAz)
+		if err := teardownRemotesOnHost(ctx, host.Host, thisAz); err != nil {
+			log.Errorf("Error tearing down bindings to %s on %s, %+v", thisAz, host.AzName, err)
+		}
+	}
 }
 
 // For tearing down our responsibility is to make sure our bindings in our local cluster are correct
-func teardownMissing(thisAz string, remoteRunning map[string]*domain.Service) {
+func teardownMissing(ctx context.Context, thisAz string, remoteRunning map[string]*domain.Service, remoteInstances map[string]bool) {
 	log.Debug("Tearing down any missing services")
-	hostport := LocalHost + ":" + DefaultRabbitPort
+	for _, vhost := range Vhosts() {
+		teardownMissingInVhost(ctx, vhost, thisAz, remoteRunning, remoteInstances)
+	}
+	log.Debug("Tearing down any missing services complete")
+}
+
+// Discovery doesn't know which vhost remote instances are in, so a binding is kept if the service is running in the
+// AZ in any vhost. Direct bindings are kept if the instance or service is running in the AZ.
+func teardownMissingInVhost(ctx context.Context, vhost string, thisAz string, remoteRunning map[string]*domain.Service, remoteInstances map[string]bool) {
+	hostport := LocalHost
 	// find all exchanges
-	exchNames, err := GetAllRemoteExchanges(getHttpClient(), hostport)
+	exchNames, err := GetAllRemoteExchanges(ctx, hostport, vhost)
 	if err != nil {
-		log.Errorf("Error determining remote exchanges %+v", err)
+		log.Errorf("Error determining remote exchanges in vhost %s %+v", vhost, err)
 		return
 	}
 	// for each exchange X
@@ -135,9 +175,9 @@ func teardownMissing(thisAz string, remoteRunning map[string]*domain.Service) {
 		if x == thisAz {
 			continue
 		}
-		bindings, err := GetAllExchangeBindings(getHttpClient(), hostport, x)
+		bindings, err := GetAllExchangeBindings(ctx, hostport, vhost, x)
 		if err != nil {
-			log.Errorf("Error getting bindings for exchange %s %+v", x, err)
+			log.Errorf("Error getting bindings for exchange %s in vhost %s %+v", x, vhost, err)
 			continue // let's just try to clear up as much as we can so soldier on
 		}
 		for _, b := range bindings {
@@ -145,20 +185,25 @@ func teardownMissing(thisAz string, remoteRunning map[string]*domain.Service) {
 			if _, ok := remoteRunning[x+b.Arguments["service"].(string)]; !ok {
 				// remove
 				log.Debug("Deleting binding for missing service %+v", b)
-				DeleteBinding(getHttpClient(), hostport, b)
+				getRabbitClient().DeleteBinding(ctx, hostport, b)
 			}
 		}
+		teardownMissingDirectInVhost(ctx, vhost, x, remoteRunning, remoteInstances)
 	}
-
-	log.Debug("Tearing down any missing services complete")
-
 }
 
 func SetupService(s *domain.Service) errors.Error {
+	return setupService(context.Background(), s, nil)
+}
+
+// setupService sets up the bindings for the service instance, recording the outcome for each cluster in res
+func setupService(ctx context.Context, s *domain.Service, res *RebindResult) (errObj errors.Error) {
 
 	if thisAz != s.AzName {
 		return nil // not in the corresponding AZ
 	}
+	start := time.Now()
+	defer func() { observeServiceOp(OP_SETUP, start, errObj != nil) }()
 
 	log.Debugf("Setting up service %+v", s)
 
@@ -168,33 +213,69 @@ func SetupService(s *domain.Service) errors.Error {
 		log.Errorf("Failed to acquire lock to setup up process %+v", err)
 		return errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", err.Error())
 	}
+	log.Debug("Acquired lock")
 
+	vs, err := withVhost(ctx, s)
 	if err != nil {
-		log.Errorf("Failed to acquire lock to setup up process %+v", err)
-		return errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", err.Error())
+		return errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", fmt.Sprintf("Error while finding the vhost of %v. %v", s.Instance, err))
 	}
-	log.Debug("Acquired lock")
+	s = vs
+	rules, errObj := getRules(s)
+	if errObj != nil {
+		return errObj
+	}
+	if errObj := setupLocal(ctx, s, rules, res); errObj != nil {
+		return errObj
+	}
+	return setupRemote(ctx, s, res)
+}
 
+// localBinding binds h2o to the service instance's queue on its own cluster, weighted by the rules
+func localBinding(s *domain.Service, rules []*domain.Rule) *domain.BindingDef {
+	b := domain.BindingDefFromService(s)
+	applyRules(rules, b, s)
+	return b
+}
+
+// remoteBindings are the bindings another cluster needs to send the service's messages to the instance's AZ, h2o to
+// the AZ's exchange for the service and h2o.direct to the AZ's direct exchange for the instance and service. They
+// don't have the rules applied so they're the same for every instance of the service.
+func remoteBindings(s *domain.Service, azName string) []*domain.BindingDef {
+	return append([]*domain.BindingDef{domain.ExchangeBindingDefFromService(s, azName)}, remoteDirectBindings(s, azName)...)
+}
+
+// remoteBound returns whether the cluster in clusterAz should be bound to azName for the service. It isn't if the
+// service is local, azName has failed over or azName can't receive federated messages from the cluster.
+func remoteBound(service string, clusterAz string, azName string, azFailedOver bool) bool {
+	return clusterAz != azName && !localServices[service] && !azFailedOver && !IsFederationDownTo(azName, clusterAz)
+}
+
+// getRules returns the binding rules for the service, or the default rule if there are none
+func getRules(s *domain.Service) ([]*domain.Rule, errors.Error) {
 	rules, err := dao.GetRules(s.Service)
 	if err != nil {
 		log.Errorf("Error retrieving binding rules %+v", err)
-		return errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", err.Error())
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", err.Error())
 	}
 	if rules == nil || len(rules) == 0 {
 		// sort out a default rule with weight 100. This means that only < 1% of messages will go over the federation links
 		rules = []*domain.Rule{&domain.Rule{Service: s.Service, Weight: 100, Version: s.Version}}
 	}
+	return rules, nil
+}
 
+// setupLocal sets up the bindings on this cluster to the service instance's queue. Caller must hold the lock.
+func setupLocal(ctx context.Context, s *domain.Service, rules []*domain.Rule, res *RebindResult) errors.Error {
 	// create new binding before deleting any old ones, that way the queue is always receiving messages
-	b := domain.BindingDefFromService(s)
-	applyRules(rules, b, s)
-	hostport := LocalHost + ":" + DefaultRabbitPort
-	err = CreateBinding(getHttpClient(), hostport, b)
+	b := localBinding(s, rules)
+	hostport := LocalHost
+	err := getRabbitClient().CreateBinding(ctx, hostport, b)
+	res.record(thisAz, err)
 	if err != nil {
 		return errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", fmt.Sprintf("Error while creating E2Q binding h2o -> %v. %v", b.Destination, err))
 	}
 
-	bindings, err := GetAllQueueBindings(getHttpClient(), hostport, b.Destination)
+	bindings, err := GetAllQueueBindings(ctx, hostport, b.Vhost, b.Destination)
 	if err != nil {
 		return errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", fmt.Sprintf("Error while querying current bindings h2o -> %v. %v", b.Destination, err))
 	}
@@ -211,117 +292,163 @@ func SetupService(s *domain.Service) errors.Error {
 				}
 				log.Debugf("Binding %+v doesn't equal %+v", b, currBinding)
 				// delete, ignore errors
-				DeleteBinding(getHttpClient(), hostport, currBinding)
+				getRabbitClient().DeleteBinding(ctx, hostport, currBinding)
 				deleted++
 			}
 		}
 	}
 
+	for _, db := range directBindings(s) {
+		if err := getRabbitClient().CreateBinding(ctx, hostport, db); err != nil {
+			return errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", fmt.Sprintf("Error while creating E2Q binding %v -> %v with key %v. %v", DIRECT_EXCHANGE, s.Instance, db.RoutingKey, err))
+		}
+	}
+
 	for _, sub := range s.Subscriptions {
 		if sub != "" {
-			err := CreateTopicBindingE2Q(getHttpClient(), LocalHost+":"+DefaultRabbitPort, raven.TOPIC_EXCHANGE, s.Instance, sub)
+			err := CreateTopicBindingE2Q(ctx, LocalHost, s.GetVhost(), raven.TOPIC_EXCHANGE, s.Instance, sub)
 			if err != nil {
 				return errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", fmt.Sprintf("Error while creating E2Q binding h2o.topic -> %v. %v", s.Instance, err))
 			}
 		}
 	}
+	if err := trimTopicBindings(ctx, s); err != nil {
+		return errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", fmt.Sprintf("Error while removing dropped topic bindings h2o.topic -> %v. %v", s.Instance, err))
+	}
+	return nil
+}
 
-	if !localServices[s.Service] {
-		if isRbFailedOver {
-			// don't do any of the remote stuff
-			log.Debug("We've failed over so not doing any remote bindings")
-			return nil
+// setupRemote sets up the bindings on the other clusters which point to this AZ for the service. Caller must hold the lock.
+func setupRemote(ctx context.Context, s *domain.Service, res *RebindResult) errors.Error {
+	if localServices[s.Service] {
+		return nil
+	}
+	if isRbFailedOver {
+		// don't do any of the remote stuff
+		log.Debug("We've failed over so not doing any remote bindings")
+		return nil
+	}
+	hosts, err := getRabbitClusterHosts()
+	if err != nil {
+		return errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", fmt.Sprintf("Error while retrieving hostnames %v", err))
+	}
+
+	// bind the remote clusters in parallel, the per host limiter bounds the load on each cluster
+	errs := make(chan errors.Error, len(hosts))
+	for _, host := range hosts {
+		// Intentionally do not apply binding rules, leave blank so we always create same binding for a service
+		// regardless of rules. This reduces the number of bindings created. Also means that it reduces cross AZ traffic
+		// if rules are applied since x-weight defaults to 1
+		if host.AzName == thisAz {
+			errs <- nil
+			continue
 		}
-		hosts, err := getRabbitClusterHosts()
-		if err != nil {
-			return errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", fmt.Sprintf("Error while retrieving hostnames %v", err))
+		if !remoteBound(s.Service, host.AzName, thisAz, isRbFailedOver) {
+			// messages for this AZ wouldn't get here from there
+			log.Debugf("Federation from %s is down so not binding it to %s", host.AzName, thisAz)
+			errs <- nil
+			continue
 		}
-
-		for _, host := range hosts {
-			// Intentionally do not apply binding rules, leave blank so we always create same binding for a service
-			// regardless of rules. This reduces the number of bindings created. Also means that it reduces cross AZ traffic
-			// if rules are applied since x-weight defaults to 1
-			if host.AzName == thisAz {
-				continue
-			}
-			eb := domain.ExchangeBindingDefFromService(s, thisAz)
-			remoteHostPort := host.Host + ":" + DefaultRabbitPort
-			err = CreateBinding(getHttpClient(), remoteHostPort, eb)
-			if err != nil {
-				return errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", fmt.Sprintf("Error while creating E2E binding h2o -> %v on %v. %v", thisAz, host, err))
+		go func(host domain.RabbitHost) {
+			for _, b := range remoteBindings(s, thisAz) {
+				err := getRabbitClient().CreateBinding(ctx, host.Host, b)
+				res.record(host.AzName, err)
+				if err != nil {
+					errs <- errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", fmt.Sprintf("Error while creating E2E binding %v -> %v on %v. %v", b.Source, b.Destination, host, err))
+					return
+				}
 			}
-
+			errs <- nil
+		}(host)
+	}
+	var firstErr errors.Error
+	for i := 0; i < len(hosts); i++ {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
 		}
 	}
+	return firstErr
+}
 
-	return nil
+// GetInstances returns every running service instance, in every AZ, from discovery
+func GetInstances() ([]*domain.Service, error) {
+	request, err := server.ScopedRequest(
+		"com.HailoOSS.kernel.discovery",
+		"instances",
+		&instances.Request{},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &instances.Response{}
+
+	if err := client.Req(request, response); err != nil {
+		return nil, err
+	}
+
+	res := make([]*domain.Service, 0, len(response.GetInstances()))
+	for _, i := range response.GetInstances() {
+		res = append(res, domain.ServiceFromInstancesProto(i))
+	}
+	return res, nil
 }
 
 func applyRules(rules []*domain.Rule, b *domain.BindingDef, s *domain.Service) {
 	if rules != nil {
-		for _, r := range rules {
-			// TODO what happens if more than one is applicable?
-			if r.IsApplicable(s) {
-				for k, v := range r.GetRuleMap() {
-					b.Arguments[k] = v
+		// rules for every vhost first so the ones for the service's vhost take precedence
+		for _, vhostRules := range []bool{false, true} {
+			for _, r := range rules {
+				// TODO what happens if more than one is applicable?
+				if (r.Vhost != "") == vhostRules && r.IsApplicable(s) {
+					for k, v := range r.GetRuleMap() {
+						b.Arguments[k] = v
+					}
 				}
-
 			}
-
 		}
 	}
 
 }
 
-func TeardownService(service string, queue string, azName string) errors.Error {
-
-	if thisAz != azName {
-		return nil // not in the corresponding AZ
+// teardownRemote removes the bindings on other clusters to the service in each vhost where there are no instances
+// left in the AZ. Caller must hold the lock.
+func teardownRemote(ctx context.Context, service string, azName string) errors.Error {
+	if localServices[service] {
+		return nil
 	}
-
-	log.Debugf("Tearing down service %s", service)
-
-	// remove binding - normally auto removed since queue should die BUT if service that's down still has it's connection but not responding then we need to remove binding
-	DeleteLocalServiceBindings(getHttpClient(), LocalHost+":"+DefaultRabbitPort, raven.EXCHANGE, queue)
-	log.Debugf("Tearing down service done %+v", service)
-	return TeardownRemoteServiceBindings(getHttpClient(), service, azName)
+	for _, vhost := range Vhosts() {
+		if errObj := teardownRemoteInVhost(ctx, vhost, service, azName); errObj != nil {
+			return errObj
+		}
+	}
+	return nil
 }
 
-func TeardownRemoteServiceBindings(httpClient *http.Client, service string, azName string) errors.Error {
-	if !localServices[service] {
+func teardownRemoteInVhost(ctx context.Context, vhost string, service string, azName string) errors.Error {
+	last, err := isLastInstanceInAz(ctx, LocalHost, vhost, service, azName)
+	if err != nil {
+		log.Error("Error while finding last instance ", err)
+	} else if last {
 
-		lock, err := getLock(service, azName)
-		defer lock.Unlock()
+		log.Debugf("Last instance in AZ in vhost %s, unbinding in other AZs", vhost)
+		hosts, err := getRabbitClusterHosts()
 		if err != nil {
-			log.Errorf("Failed to acquire lock to tear down process %+v", err)
-			return errors.BadRequest("com.HailoOSS.kernel.binding.teardownservice", err.Error())
+			return errors.InternalServerError("com.HailoOSS.kernel.binding.teardownservice", fmt.Sprintf("Error while retrieving hostnames %v", err))
 		}
 
-		if err != nil {
-			log.Errorf("Failed to acquire lock to tear down process %+v", err)
-			return errors.BadRequest("com.HailoOSS.kernel.binding.teardownservice", err.Error())
-		}
-		log.Debug("Acquired lock")
-		last, err := isLastInstanceInAz(httpClient, LocalHost+":"+DefaultRabbitPort, service, azName)
-		if err != nil {
-			log.Error("Error while finding last instance ", err)
-		} else if last {
+		for _, host := range hosts {
+			if host.AzName == thisAz {
+				continue
+			}
 
-			log.Debug("Last instance in AZ, unbinding in other AZs")
-			hosts, err := getRabbitClusterHosts()
+			err = DeleteRemoteServiceBindings(ctx, host.Host, vhost, service, azName)
 			if err != nil {
-				return errors.InternalServerError("com.HailoOSS.kernel.binding.teardownservice", fmt.Sprintf("Error while retrieving hostnames %v", err))
+				return errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", fmt.Sprintf("Error while deleting service bindings %v", err))
 			}
-
-			for _, host := range hosts {
-				if host.AzName == thisAz {
-					continue
-				}
-
-				err = DeleteRemoteServiceBindings(httpClient, host.Host+":"+DefaultRabbitPort, service, azName)
-				if err != nil {
-					return errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", fmt.Sprintf("Error while deleting service bindings %v", err))
-				}
+			err = DeleteRemoteDirectBindings(ctx, host.Host, vhost, service, azName)
+			if err != nil {
+				return errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", fmt.Sprintf("Error while deleting direct service bindings %v", err))
 			}
 		}
 	}
@@ -330,19 +457,19 @@ func TeardownRemoteServiceBindings(httpClient *http.Client, service string, azNa
 
 func getLock(service string, azName string) (sync.Lock, error) {
 	lockRt := fmt.Sprintf(LOCK_STRING, service, azName)
-	return sync.RegionLock([]byte(lockRt))
+	return regionLock([]byte(lockRt))
 }
 
-func isLastInstanceInAz(httpClient *http.Client, hostport string, serviceName string, azName string) (bool, error) {
+func isLastInstanceInAz(ctx context.Context, host string, vhost string, serviceName string, azName string) (bool, error) {
 	// Check all h2o -> q bindings for this service name. If none available then this was last instance
-	bindings, err := GetBindingsForExchange(httpClient, hostport, raven.EXCHANGE)
+	bindings, err := getRabbitClient().GetBindingsForSource(ctx, host, vhost, raven.EXCHANGE)
 	if err != nil {
 		log.Errorf("Can't tell whether this is the last instance in the AZ %+v", err)
 		return false, err
 	}
 	found := false
 	log.Debugf("Checking last instance %s", serviceName)
-	for _, v := range *bindings {
+	for _, v := range bindings {
 		args := v.Arguments
 		if args != nil {
 			found = args["service"] == serviceName && v.DestinationType == "queue"
@@ -0,0 +1,105 @@
+package binding
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/rabbit"
+	"github.com/HailoOSS/platform/raven"
+)
+
+// Subscription is an h2o.topic binding of a queue to a topic
+type Subscription struct {
+	Vhost string
+	Queue string
+	Topic string
+}
+
+func (s *Subscription) key() string {
+	return s.Vhost + "|" + s.Queue + "|" + s.Topic
+}
+
+// DeleteTopicBindingE2Q removes the bindings from the exchange to the queue with the topic as the routing key and
+// returns whether there were any
+func DeleteTopicBindingE2Q(ctx context.Context, host string, vhost string, from string, destQueue string, topic string) (bool, error) {
+	bindings, err := getRabbitClient().GetBindingsBetween(ctx, host, vhost, from, domain.QUEUE_S, destQueue)
+	if err != nil {
+		return false, err
+	}
+	found := false
+	for _, b := range bindings {
+		if b.RoutingKey != topic {
+			continue
+		}
+		found = true
+		if err := getRabbitClient().DeleteBinding(ctx, host, b); err != nil && !rabbit.IsNotFound(err) {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+// ListSubscriptions returns the h2o.topic bindings on this cluster of the queue, the topic or both, sorted by vhost,
+// queue and topic. The vhost defaults to the queue's, or every managed vhost for a topic.
+func ListSubscriptions(ctx context.Context, vhost string, queue string, topic string) ([]*Subscription, error) {
+	if queue == "" && topic == "" {
+		return nil, fmt.Errorf("A queue or topic is required")
+	}
+	vhosts := []string{vhost}
+	if vhost == "" && queue != "" {
+		v, err := QueueVhost(ctx, queue)
+		if err != nil {
+			return nil, err
+		}
+		vhosts = []string{v}
+	} else if vhost == "" {
+		vhosts = Vhosts()
+	}
+
+	res := make([]*Subscription, 0)
+	for _, v := range vhosts {
+		var bindings []*domain.BindingDef
+		var err error
+		if queue != "" {
+			bindings, err = getRabbitClient().GetBindingsBetween(ctx, LocalHost, v, raven.TOPIC_EXCHANGE, domain.QUEUE_S, queue)
+		} else {
+			bindings, err = getRabbitClient().GetBindingsForSource(ctx, LocalHost, v, raven.TOPIC_EXCHANGE)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error while retrieving topic bindings in vhost %s %v", v, err)
+		}
+		for _, b := range bindings {
+			if b.DestinationType != string(domain.QUEUE) || (topic != "" && b.RoutingKey != topic) {
+				continue
+			}
+			res = append(res, &Subscription{Vhost: b.Vhost, Queue: b.Destination, Topic: b.RoutingKey})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].key() < res[j].key() })
+	return res, nil
+}
+
+// trimTopicBindings removes the instance's h2o.topic bindings for topics it no longer advertises
+func trimTopicBindings(ctx context.Context, s *domain.Service) error {
+	advertised := make(map[string]bool, len(s.Subscriptions))
+	for _, sub := range s.Subscriptions {
+		advertised[sub] = true
+	}
+	bound, err := ListSubscriptions(ctx, s.GetVhost(), s.Instance, "")
+	if err != nil {
+		return err
+	}
+	for _, sub := range bound {
+		if advertised[sub.Topic] {
+			continue
+		}
+		log.Debugf("Unsubscribing %s from topic %s which it no longer advertises", s.Instance, sub.Topic)
+		if _, err := DeleteTopicBindingE2Q(ctx, LocalHost, sub.Vhost, raven.TOPIC_EXCHANGE, s.Instance, sub.Topic); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,519 @@
+package binding
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/dao"
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/rabbit"
+	"github.com/HailoOSS/binding-service/util"
+	"github.com/HailoOSS/platform/raven"
+)
+
+const (
+	DIRECT_EXCHANGE   = "h2o.direct"
+	AZ_EXCHANGE_TYPE  = "headers"     // the exchanges named after each AZ, which are federated
+	FEDERATION_POLICY = "federate-%s" // name of the policy federating an AZ exchange, when we create it
+	UPSTREAM_ACK_MODE = "no-ack"
+	UPSTREAM_EXPIRES  = 360000
+)
+
+// kinds of broker object in the topology
+const (
+	TOPOLOGY_CLUSTER  = "cluster"
+	TOPOLOGY_EXCHANGE = "exchange"
+	TOPOLOGY_UPSTREAM = "upstream"
+	TOPOLOGY_POLICY   = "policy"
+	TOPOLOGY_QUEUE    = "queue"
+	TOPOLOGY_BINDING  = "binding"
+)
+
+// TopologyProblem is a missing or misconfigured exchange, queue, binding, federation upstream or policy on a cluster
+type TopologyProblem struct {
+	AzName    string
+	Host      string
+	Vhost     string
+	Kind      string
+	Name      string
+	Problem   string
+	Repaired  bool
+	RepairErr error
+}
+
+// Key identifies the object, "<az>-<vhost>-<kind>.<name>"
+func (p *TopologyProblem) Key() string {
+	return fmt.Sprintf("%s-%s-%s.%s", p.AzName, p.Vhost, p.Kind, p.Name)
+}
+
+// topology is what should exist in each managed vhost of a cluster
+type topology struct {
+	exchanges     map[string]*domain.ExchangeDef
+	upstreams     map[string]map[string]interface{} // keyed by upstream name, the node it federates from
+	federate      string                            // the exchange which should have a federation policy
+	policies      map[string]*rabbit.Policy         // the policies we own in every vhost, keyed by name
+	deadLetters   []*domain.DeadLetterRule          // services with their own dead-letter queue
+	queuePolicies []*domain.QueuePolicyRule         // services with a policy for their instance queues
+}
+
+// vhostState is what exists in a vhost of a cluster
+type vhostState struct {
+	exchanges []*domain.ExchangeDef
+	queues    []*rabbit.Queue      // just the queues in the topology
+	bindings  []*domain.BindingDef // from the dead-letter exchange and the AZ's direct exchange
+	upstreams []*rabbit.Parameter
+	policies  []*rabbit.Policy
+}
+
+// upstreamValue is the definition of the federation upstream for a node
+func upstreamValue(node string) map[string]interface{} {
+	return map[string]interface{}{"ack-mode": UPSTREAM_ACK_MODE, "expires": UPSTREAM_EXPIRES, "uri": upstreamURI(node)}
+}
+
+// expectedTopology returns what should exist on the cluster for azName: the h2o exchanges, an exchange and a direct
+// exchange for every AZ, an upstream for each node of the other clusters, policies federating the AZ's own exchanges,
+// the binding of its direct exchange to h2o.direct and the dead-letter exchange with its queue and policy. The dead-letter queues and queue policies of services are added by setting
+// deadLetters and queuePolicies.
+func expectedTopology(azName string, clusters map[string]*util.RabbitCluster) *topology {
+	t := &topology{
+		exchanges: map[string]*domain.ExchangeDef{
+			raven.EXCHANGE:       {Name: raven.EXCHANGE, Type: "headers", Durable: true},
+			raven.TOPIC_EXCHANGE: {Name: raven.TOPIC_EXCHANGE, Type: "topic", Durable: true},
+			DIRECT_EXCHANGE:      {Name: DIRECT_EXCHANGE, Type: "direct", Durable: true},
+			DEADLETTER_EXCHANGE:  {Name: DEADLETTER_EXCHANGE, Type: "headers", Durable: true},
+		},
+		upstreams: make(map[string]map[string]interface{}),
+		federate:  azName,
+		policies:  map[string]*rabbit.Policy{DEADLETTER_POLICY: deadLetterPolicy()},
+	}
+	for az, c := range clusters {
+		t.exchanges[az] = &domain.ExchangeDef{Name: az, Type: AZ_EXCHANGE_TYPE, Durable: true}
+		t.exchanges[DirectAzExchange(az)] = &domain.ExchangeDef{Name: DirectAzExchange(az), Type: DIRECT_AZ_EXCHANGE_TYPE, Durable: true}
+		if az == azName {
+			continue
+		}
+		for _, n := range c.Nodes {
+			if h, _, err := net.SplitHostPort(n); err == nil {
+				n = h
+			}
+			t.upstreams[n] = upstreamValue(n)
+		}
+	}
+	return t
+}
+
+// queues returns the queues which should exist in the vhost with their arguments
+func (t *topology) queues(vhost string) map[string]map[string]interface{} {
+	res := map[string]map[string]interface{}{DEADLETTER_QUEUE: deadLetterQueueArgs(), UNROUTABLE_QUEUE: unroutableQueueArgs()}
+	for _, r := range t.deadLetters {
+		if r.IsApplicable(vhost) {
+			res[DeadLetterQueue(r.Service)] = deadLetterQueueArgs()
+		}
+	}
+	return res
+}
+
+// policiesFor returns the policies we own which should exist in the vhost, keyed by name
+func (t *topology) policiesFor(vhost string) map[string]*rabbit.Policy {
+	res := queuePolicies(t.queuePolicies, vhost)
+	for name, p := range t.policies {
+		res[name] = p
+	}
+	if t.federate != "" {
+		p := directFederationPolicy(t.federate)
+		res[p.Name] = p
+	}
+	return res
+}
+
+// bindings returns the bindings from the dead-letter exchange and the AZ's direct exchange which should exist in the
+// vhost
+func (t *topology) bindings(vhost string) []*domain.BindingDef {
+	names := make([]string, 0)
+	for name := range t.queues(vhost) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	res := make([]*domain.BindingDef, 0, len(names))
+	for _, name := range names {
+		res = append(res, deadLetterBinding(vhost, name))
+	}
+	if t.federate != "" {
+		res = append(res, directAzBinding(vhost, t.federate))
+	}
+	return res
+}
+
+// compareTopology returns the objects in the vhost which are missing or don't match the topology
+func compareTopology(t *topology, vhost string, state *vhostState) []*TopologyProblem {
+	res := make([]*TopologyProblem, 0)
+
+	actualExchanges := make(map[string]*domain.ExchangeDef, len(state.exchanges))
+	for _, e := range state.exchanges {
+		actualExchanges[e.Name] = e
+	}
+	names := make([]string, 0, len(t.exchanges))
+	for name := range t.exchanges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		want := t.exchanges[name]
+		got, ok := actualExchanges[name]
+		switch {
+		case !ok:
+			res = append(res, &TopologyProblem{Kind: TOPOLOGY_EXCHANGE, Name: name, Problem: "missing"})
+		case got.Type != want.Type:
+			res = append(res, &TopologyProblem{Kind: TOPOLOGY_EXCHANGE, Name: name, Problem: fmt.Sprintf("type is %s not %s", got.Type, want.Type)})
+		case got.Durable != want.Durable:
+			res = append(res, &TopologyProblem{Kind: TOPOLOGY_EXCHANGE, Name: name, Problem: fmt.Sprintf("durable is %v not %v", got.Durable, want.Durable)})
+		default:
+			for _, k := range sortedArgs(want.Arguments) {
+				if fmt.Sprint(got.Arguments[k]) != fmt.Sprint(want.Arguments[k]) {
+					res = append(res, &TopologyProblem{Kind: TOPOLOGY_EXCHANGE, Name: name, Problem: fmt.Sprintf("argument %s is %v not %v", k, got.Arguments[k], want.Arguments[k])})
+					break
+				}
+			}
+		}
+	}
+
+	actualQueues := make(map[string]*rabbit.Queue, len(state.queues))
+	for _, q := range state.queues {
+		actualQueues[q.Name] = q
+	}
+	queues := t.queues(vhost)
+	names = names[:0]
+	for name := range queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		got, ok := actualQueues[name]
+		if !ok {
+			res = append(res, &TopologyProblem{Kind: TOPOLOGY_QUEUE, Name: name, Problem: "missing"})
+			continue
+		}
+		for _, k := range sortedArgs(queues[name]) {
+			if fmt.Sprint(got.Arguments[k]) != fmt.Sprint(queues[name][k]) {
+				res = append(res, &TopologyProblem{Kind: TOPOLOGY_QUEUE, Name: name, Problem: fmt.Sprintf("argument %s is %v not %v", k, got.Arguments[k], queues[name][k])})
+				break
+			}
+		}
+	}
+
+	expectedBindings := t.bindings(vhost)
+	for _, want := range expectedBindings {
+		found := false
+		for _, got := range state.bindings {
+			if got.Destination == want.Destination && sameBinding(got, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, &TopologyProblem{Kind: TOPOLOGY_BINDING, Name: bindingName(want), Problem: "missing"})
+		}
+	}
+	for _, got := range state.bindings {
+		if !strings.HasPrefix(got.Destination, DEADLETTER_QUEUE+".") {
+			// only the service dead-letter queues are ours to clean up
+			continue
+		}
+		if _, ok := queues[got.Destination]; !ok {
+			res = append(res, &TopologyProblem{Kind: TOPOLOGY_BINDING, Name: bindingName(got), Problem: "stale"})
+		}
+	}
+
+	actualUpstreams := make(map[string]*rabbit.Parameter, len(state.upstreams))
+	for _, u := range state.upstreams {
+		actualUpstreams[u.Name] = u
+	}
+	names = names[:0]
+	for name := range t.upstreams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		got, ok := actualUpstreams[name]
+		if !ok {
+			res = append(res, &TopologyProblem{Kind: TOPOLOGY_UPSTREAM, Name: name, Problem: "missing"})
+			continue
+		}
+		for _, k := range sortedArgs(t.upstreams[name]) {
+			if fmt.Sprint(got.Value[k]) != fmt.Sprint(t.upstreams[name][k]) {
+				// the uri has credentials in it so don't report its value
+				res = append(res, &TopologyProblem{Kind: TOPOLOGY_UPSTREAM, Name: name, Problem: k + " differs"})
+				break
+			}
+		}
+	}
+
+	if t.federate != "" && !isFederated(t.federate, state.policies) {
+		res = append(res, &TopologyProblem{Kind: TOPOLOGY_POLICY, Name: fmt.Sprintf(FEDERATION_POLICY, t.federate), Problem: "missing"})
+	}
+	actualPolicies := make(map[string]*rabbit.Policy, len(state.policies))
+	for _, p := range state.policies {
+		actualPolicies[p.Name] = p
+	}
+	policies := t.policiesFor(vhost)
+	names = names[:0]
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if problem := comparePolicy(policies[name], actualPolicies[name]); problem != "" {
+			res = append(res, &TopologyProblem{Kind: TOPOLOGY_POLICY, Name: name, Problem: problem})
+		}
+	}
+	for _, p := range state.policies {
+		if _, ok := policies[p.Name]; !ok && isQueuePolicy(p.Name) {
+			res = append(res, &TopologyProblem{Kind: TOPOLOGY_POLICY, Name: p.Name, Problem: "stale"})
+		}
+	}
+	return res
+}
+
+// comparePolicy returns what's wrong with the policy, nothing if it matches
+func comparePolicy(want *rabbit.Policy, got *rabbit.Policy) string {
+	switch {
+	case got == nil:
+		return "missing"
+	case got.Pattern != want.Pattern:
+		return fmt.Sprintf("pattern is %s not %s", got.Pattern, want.Pattern)
+	case got.ApplyTo != want.ApplyTo:
+		return fmt.Sprintf("apply-to is %s not %s", got.ApplyTo, want.ApplyTo)
+	case got.Priority != want.Priority:
+		return fmt.Sprintf("priority is %d not %d", got.Priority, want.Priority)
+	}
+	for _, k := range sortedArgs(want.Definition) {
+		if fmt.Sprint(got.Definition[k]) != fmt.Sprint(want.Definition[k]) {
+			return fmt.Sprintf("%s is %v not %v", k, got.Definition[k], want.Definition[k])
+		}
+	}
+	for _, k := range sortedArgs(got.Definition) {
+		if _, ok := want.Definition[k]; !ok {
+			return fmt.Sprintf("%s is %v not unset", k, got.Definition[k])
+		}
+	}
+	return ""
+}
+
+// bindingName identifies a binding in a problem, "<source>-><destination>"
+func bindingName(b *domain.BindingDef) string {
+	return b.Source + "->" + b.Destination
+}
+
+// isFederated returns whether one of the policies federates the exchange from all the upstreams
+func isFederated(exchange string, policies []*rabbit.Policy) bool {
+	for _, p := range policies {
+		if set, _ := p.Definition["federation-upstream-set"].(string); set != "all" || p.ApplyTo == "queues" {
+			continue
+		}
+		if re, err := regexp.Compile(p.Pattern); err == nil && re.MatchString(exchange) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedArgs(m map[string]interface{}) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// getTopologyRules returns the dead-letter and queue policy rules of every service
+func getTopologyRules() ([]*domain.DeadLetterRule, []*domain.QueuePolicyRule, error) {
+	deadLetters, err := dao.GetDeadLetterRules()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error while retrieving dead-letter rules %v", err)
+	}
+	queuePolicies, err := dao.GetQueuePolicyRules()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error while retrieving queue policy rules %v", err)
+	}
+	return deadLetters, queuePolicies, nil
+}
+
+// setupLocalTopology creates the objects this service's rules need on this AZ's cluster: the dead-letter exchange,
+// queues (including the one for unroutable messages), bindings and policy, the queue policies, and the direct
+// exchanges with the federation and binding of this AZ's. The bindings and
+// queue policies of services which don't have a rule any more are removed. Only the leader runs it.
+func setupLocalTopology(ctx context.Context) {
+	deadLetters, queuePolicies, err := getTopologyRules()
+	if err != nil {
+		log.Errorf("Error setting up the topology %+v", err)
+		return
+	}
+	clusters, err := util.GetRabbitClusters()
+	if err != nil {
+		log.Errorf("Error while retrieving rabbit clusters to set up the topology %+v", err)
+		return
+	}
+	t := expectedTopology(thisAz, clusters)
+	t.deadLetters, t.queuePolicies = deadLetters, queuePolicies
+	for _, vhost := range Vhosts() {
+		problems, err := checkVhostTopology(ctx, LocalHost, vhost, t)
+		if err != nil {
+			log.Errorf("Error checking the topology in vhost %s %+v", vhost, err)
+			continue
+		}
+		for _, p := range problems {
+			if !isDeadLetterProblem(p) && !isDirectProblem(p) && !(p.Kind == TOPOLOGY_POLICY && isQueuePolicy(p.Name)) {
+				continue
+			}
+			p.AzName, p.Host, p.Vhost = thisAz, LocalHost, vhost
+			repairTopology(ctx, p, t)
+		}
+	}
+}
+
+// CheckTopology compares the exchanges, queues, bindings, federation upstreams and policies in each managed vhost of
+// every cluster against what should be there. If repair is set the missing and misconfigured objects are created and
+// stale bindings and queue policies are removed.
+func CheckTopology(ctx context.Context, repair bool) ([]*TopologyProblem, error) {
+	clusters, err := util.GetRabbitClusters()
+	if err != nil {
+		return nil, err
+	}
+	deadLetters, queuePolicies, err := getTopologyRules()
+	if err != nil {
+		return nil, err
+	}
+	azs := make([]string, 0, len(clusters))
+	for az := range clusters {
+		azs = append(azs, az)
+	}
+	sort.Strings(azs)
+
+	res := make([]*TopologyProblem, 0)
+	for _, az := range azs {
+		host := getRabbitClient().PickNode(clusters[az].ManagementHosts())
+		t := expectedTopology(az, clusters)
+		t.deadLetters, t.queuePolicies = deadLetters, queuePolicies
+		for _, vhost := range Vhosts() {
+			problems, err := checkVhostTopology(ctx, host, vhost, t)
+			if err != nil {
+				log.Errorf("Error checking the topology of %s in vhost %s %+v", az, vhost, err)
+				problems = []*TopologyProblem{{Kind: TOPOLOGY_CLUSTER, Name: az, Problem: err.Error()}}
+			}
+			for _, p := range problems {
+				p.AzName, p.Host, p.Vhost = az, host, vhost
+				if repair && p.Kind != TOPOLOGY_CLUSTER {
+					p.RepairErr = repairTopology(ctx, p, t)
+					p.Repaired = p.RepairErr == nil
+				}
+			}
+			res = append(res, problems...)
+		}
+	}
+	return res, nil
+}
+
+func checkVhostTopology(ctx context.Context, host string, vhost string, t *topology) ([]*TopologyProblem, error) {
+	state := &vhostState{queues: make([]*rabbit.Queue, 0)}
+	var err error
+	if state.exchanges, err = getRabbitClient().GetExchanges(ctx, host, vhost); err != nil {
+		return nil, fmt.Errorf("Error while retrieving exchanges %v", err)
+	}
+	for name := range t.queues(vhost) {
+		q, err := getRabbitClient().GetQueue(ctx, host, vhost, name)
+		if rabbit.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("Error while retrieving queue %s %v", name, err)
+		}
+		state.queues = append(state.queues, q)
+	}
+	// a missing dead-letter exchange has no bindings
+	if state.bindings, err = getRabbitClient().GetBindingsForSource(ctx, host, vhost, DEADLETTER_EXCHANGE); err != nil && !rabbit.IsNotFound(err) {
+		return nil, fmt.Errorf("Error while retrieving dead-letter bindings %v", err)
+	}
+	if t.federate != "" {
+		direct, err := getRabbitClient().GetBindingsForSource(ctx, host, vhost, DirectAzExchange(t.federate))
+		if err != nil && !rabbit.IsNotFound(err) {
+			return nil, fmt.Errorf("Error while retrieving direct bindings %v", err)
+		}
+		state.bindings = append(state.bindings, direct...)
+	}
+	if state.upstreams, err = getRabbitClient().GetFederationUpstreams(ctx, host, vhost); err != nil {
+		return nil, fmt.Errorf("Error while retrieving federation upstreams %v", err)
+	}
+	if state.policies, err = getRabbitClient().GetPolicies(ctx, host, vhost); err != nil {
+		return nil, fmt.Errorf("Error while retrieving policies %v", err)
+	}
+	return compareTopology(t, vhost, state), nil
+}
+
+// repairTopology creates the object or removes a stale binding or policy. An exchange with the wrong type or a queue with the
+// wrong arguments can't be fixed without deleting it so that fails.
+func repairTopology(ctx context.Context, p *TopologyProblem, t *topology) error {
+	hostname, port := p.Host, getRabbitClient().Port
+	if h, ps, err := net.SplitHostPort(p.Host); err == nil {
+		hostname = h
+		port, _ = strconv.Atoi(ps)
+	}
+	var err error
+	switch p.Kind {
+	case TOPOLOGY_EXCHANGE:
+		e := t.exchanges[p.Name]
+		err = CreateExchange(ctx, &domain.RabbitExchange{Hostname: hostname, Hostport: port, Vhost: p.Vhost, Name: e.Name, Xtype: e.Type, Options: e.Arguments})
+	case TOPOLOGY_UPSTREAM:
+		err = CreateUpstreams(ctx, p.Vhost, []string{p.Name}, hostname, port)
+	case TOPOLOGY_QUEUE:
+		err = CreateQueue(ctx, &domain.RabbitQueue{Hostname: hostname, Hostport: port, Vhost: p.Vhost, Name: p.Name, Options: t.queues(p.Vhost)[p.Name]})
+	case TOPOLOGY_BINDING:
+		err = repairBinding(ctx, p, t)
+	case TOPOLOGY_POLICY:
+		if p.Problem == "stale" {
+			err = getRabbitClient().DeletePolicy(ctx, p.Host, p.Vhost, p.Name)
+		} else if owned, ok := t.policiesFor(p.Vhost)[p.Name]; ok {
+			policy := *owned
+			policy.Vhost = p.Vhost
+			err = getRabbitClient().PutPolicy(ctx, p.Host, &policy)
+		} else {
+			err = CreateRabbitPolicy(ctx, p.Vhost, "^"+regexp.QuoteMeta(t.federate)+"$", p.Name, hostname, port)
+		}
+	}
+	if err != nil {
+		log.Errorf("Error repairing %s %+v", p.Key(), err)
+		return err
+	}
+	log.Infof("Repaired %s which was %s", p.Key(), p.Problem)
+	return nil
+}
+
+// repairBinding creates a missing dead-letter or direct binding or removes a stale dead-letter binding
+func repairBinding(ctx context.Context, p *TopologyProblem, t *topology) error {
+	if p.Problem != "stale" {
+		for _, b := range t.bindings(p.Vhost) {
+			if bindingName(b) == p.Name {
+				return getRabbitClient().CreateBinding(ctx, p.Host, b)
+			}
+		}
+		return fmt.Errorf("No binding %s in the topology", p.Name)
+	}
+	bindings, err := getRabbitClient().GetBindingsForSource(ctx, p.Host, p.Vhost, DEADLETTER_EXCHANGE)
+	if err != nil {
+		return err
+	}
+	for _, b := range bindings {
+		if bindingName(b) != p.Name {
+			continue
+		}
+		if err := getRabbitClient().DeleteBinding(ctx, p.Host, b); err != nil && !rabbit.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
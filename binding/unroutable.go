@@ -0,0 +1,169 @@
+package binding
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/event"
+	"github.com/HailoOSS/binding-service/rabbit"
+	"github.com/HailoOSS/binding-service/util"
+)
+
+var (
+	unroutableWindow  = flag.Duration("binding_unroutable_window", 5*time.Minute, "How long unroutable messages are kept for attributing them to services, a service is receiving unroutable traffic if it had any in this window")
+	unroutablePoll    = flag.Duration("binding_unroutable_poll", time.Minute, "How often the leader checks for services receiving unroutable traffic on its cluster")
+	unroutableSamples = flag.Int("binding_unroutable_samples", 100, "How many unroutable messages are sampled from each vhost of a cluster to attribute them to services")
+
+	unroutable = &unroutableTracker{services: make(map[string]bool)}
+)
+
+const (
+	UNROUTABLE_QUEUE      = "h2o.unroutable" // keeps the messages h2o couldn't route for a window
+	UNROUTABLE_MAX_LENGTH = 1000
+	UNKNOWN_SERVICE       = "unknown" // unroutable messages without a service header
+)
+
+// UnroutableReport is how many of the messages h2o couldn't route recently in a vhost of a cluster were for each
+// service, from a sample of them
+type UnroutableReport struct {
+	AzName   string
+	Vhost    string
+	Messages int            // in the window
+	Sampled  int            // of the messages
+	Services map[string]int // sampled messages by service
+}
+
+// unroutableQueueArgs expire the messages after the window so that the queue only has the recent ones
+func unroutableQueueArgs() map[string]interface{} {
+	return map[string]interface{}{"x-max-length": UNROUTABLE_MAX_LENGTH, "x-message-ttl": int(*unroutableWindow / time.Millisecond)}
+}
+
+// CheckUnroutable samples the recently unroutable messages in each managed vhost of the cluster in azName, or every
+// cluster if it's empty, and attributes them to services. The messages are requeued.
+func CheckUnroutable(ctx context.Context, azName string) ([]*UnroutableReport, error) {
+	hosts, err := util.GetRabbitHosts()
+	if err != nil {
+		return nil, err
+	}
+	azs := make([]string, 0, len(hosts))
+	for az := range hosts {
+		if azName == "" || az == azName {
+			azs = append(azs, az)
+		}
+	}
+	sort.Strings(azs)
+
+	res := make([]*UnroutableReport, 0)
+	for _, az := range azs {
+		host := getRabbitClient().PickNode(hosts[az])
+		for _, vhost := range Vhosts() {
+			r, err := sampleUnroutable(ctx, host, vhost)
+			if err != nil {
+				return nil, fmt.Errorf("Error while sampling unroutable messages on %s in vhost %s %v", az, vhost, err)
+			}
+			r.AzName = az
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
+
+func sampleUnroutable(ctx context.Context, host string, vhost string) (*UnroutableReport, error) {
+	q, err := getRabbitClient().GetQueue(ctx, host, vhost, UNROUTABLE_QUEUE)
+	if err != nil {
+		return nil, err
+	}
+	res := &UnroutableReport{Vhost: vhost, Messages: q.Messages, Services: make(map[string]int)}
+	if q.Messages == 0 {
+		return res, nil
+	}
+	messages, err := getRabbitClient().GetMessages(ctx, host, vhost, UNROUTABLE_QUEUE, *unroutableSamples)
+	if err != nil {
+		return nil, err
+	}
+	res.Sampled, res.Services = attributeUnroutable(messages)
+	return res, nil
+}
+
+// attributeUnroutable counts the messages by their service header. Messages dead-lettered by a queue were routable
+// so they're skipped.
+func attributeUnroutable(messages []*rabbit.Message) (int, map[string]int) {
+	sampled := 0
+	res := make(map[string]int)
+	for _, m := range messages {
+		if _, ok := m.Properties.Headers["x-death"]; ok {
+			continue
+		}
+		sampled++
+		service, _ := m.Properties.Headers["service"].(string)
+		if service == "" {
+			service = UNKNOWN_SERVICE
+		}
+		res[service]++
+	}
+	return sampled, res
+}
+
+// unroutableTracker remembers which services were receiving unroutable traffic, keyed by "<az>|<vhost>|<service>"
+type unroutableTracker struct {
+	sync.Mutex
+	services map[string]bool
+}
+
+// update records the services in the reports and returns the reports of the vhosts with services which weren't
+// receiving unroutable traffic before
+func (t *unroutableTracker) update(reports []*UnroutableReport) []*UnroutableReport {
+	t.Lock()
+	defer t.Unlock()
+	seen := make(map[string]bool)
+	res := make([]*UnroutableReport, 0)
+	for _, r := range reports {
+		changed := false
+		for service := range r.Services {
+			key := r.AzName + "|" + r.Vhost + "|" + service
+			seen[key] = true
+			changed = changed || !t.services[key]
+		}
+		if changed {
+			res = append(res, r)
+		}
+	}
+	t.services = seen
+	return res
+}
+
+// ServiceNames returns the services in the report sorted by name
+func (r *UnroutableReport) ServiceNames() []string {
+	res := make([]string, 0, len(r.Services))
+	for s := range r.Services {
+		res = append(res, s)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// unroutableLoop publishes an event whenever services start receiving unroutable traffic on this AZ's cluster, until
+// the context is done. Only the leader runs it.
+func unroutableLoop(ctx context.Context) {
+	for {
+		reports, err := CheckUnroutable(ctx, thisAz)
+		if err != nil {
+			log.Errorf("Error checking for unroutable messages %+v", err)
+		} else {
+			for _, r := range unroutable.update(reports) {
+				log.Warnf("Services receiving unroutable messages on %s in vhost %s: %v", r.AzName, r.Vhost, r.Services)
+				event.PubUnroutable(r.AzName, r.Vhost, r.ServiceNames(), r.Messages)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(*unroutablePoll):
+		}
+	}
+}
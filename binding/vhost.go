@@ -0,0 +1,82 @@
+package binding
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/rabbit"
+)
+
+var (
+	vhosts = flag.String("binding_vhosts", domain.DEFAULT_VHOST, "Comma separated vhosts to manage bindings in, the first is the one this service is connected to")
+)
+
+// Vhosts returns the vhosts bindings are managed in
+func Vhosts() []string {
+	res := make([]string, 0)
+	for _, v := range strings.Split(*vhosts, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	if len(res) == 0 {
+		res = append(res, domain.DEFAULT_VHOST)
+	}
+	return res
+}
+
+// IsManagedVhost returns whether bindings are managed in the vhost
+func IsManagedVhost(vhost string) bool {
+	for _, v := range Vhosts() {
+		if v == vhost {
+			return true
+		}
+	}
+	return false
+}
+
+// localVhost is the vhost this service's own queue lives in
+func localVhost() string {
+	return Vhosts()[0]
+}
+
+// QueueVhost returns the managed vhost the queue lives in on this cluster
+func QueueVhost(ctx context.Context, queue string) (string, error) {
+	return queueVhostOn(ctx, LocalHost, queue)
+}
+
+// queueVhostOn returns the managed vhost the queue lives in on the host's cluster
+func queueVhostOn(ctx context.Context, host string, queue string) (string, error) {
+	vs := Vhosts()
+	if len(vs) == 1 {
+		// nothing to choose between, don't bother asking rabbit
+		return vs[0], nil
+	}
+	for _, v := range vs {
+		_, err := getRabbitClient().GetQueue(ctx, host, v, queue)
+		if err == nil {
+			return v, nil
+		}
+		if !rabbit.IsNotFound(err) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("Queue %s doesn't exist in any of the vhosts %v", queue, vs)
+}
+
+// withVhost returns a copy of the service with the vhost of its queue filled in
+func withVhost(ctx context.Context, s *domain.Service) (*domain.Service, error) {
+	if s.Vhost != "" {
+		return s, nil
+	}
+	vhost, err := QueueVhost(ctx, s.Instance)
+	if err != nil {
+		return nil, err
+	}
+	c := *s
+	c.Vhost = vhost
+	return &c, nil
+}
@@ -41,7 +41,7 @@ func CreateRule(rule *domain.Rule) error {
 			return fmt.Errorf("Error while trying to get binding rules %s", err)
 		}
 		for _, r := range existing {
-			if rule.Version == r.Version {
+			if rule.Version == r.Version && rule.Vhost == r.Vhost {
 				// delete
 				err = unsafeDelete(r)
 				if err != nil {
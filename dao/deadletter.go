@@ -0,0 +1,118 @@
+package dao
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/gossie/src/gossie"
+	"github.com/HailoOSS/service/cassandra"
+)
+
+// Defines crud actions for dead-letter rules. They're all read at once to set up the brokers, so they're kept in a
+// single row with a column per rule.
+
+const (
+	DEADLETTER_RULES_CF = "binding_deadletter_rules"
+	DEADLETTER_ROW      = "rules"
+)
+
+func CreateDeadLetterRule(rule *domain.DeadLetterRule) error {
+	log.Debugf("Creating dead-letter rule %+v", rule)
+	pool, err := cassandra.ConnectionPool(BINDING_KEYSPACE)
+	if err != nil {
+		return fmt.Errorf("Error while getting cassandra connection %s", err)
+	}
+	bytes, err := json.Marshal(rule)
+	if err != nil {
+		return fmt.Errorf("Error while marshalling json %s", err)
+	}
+
+	lock, err := getLock(DEADLETTER_ROW)
+	if err != nil {
+		return fmt.Errorf("Error while attempting to create lock %s", err)
+	}
+	defer lock.Unlock()
+
+	existing, err := GetDeadLetterRules()
+	if err != nil {
+		return fmt.Errorf("Error while trying to get dead-letter rules %s", err)
+	}
+	for _, r := range existing {
+		if *r == *rule {
+			return nil
+		}
+	}
+
+	var row gossie.Row
+	row.Key, _ = gossie.Marshal(DEADLETTER_ROW, gossie.AsciiType)
+	colName, _ := gossie.Marshal(getHash(bytes), gossie.AsciiType)
+	colVal, _ := gossie.Marshal(string(bytes), gossie.AsciiType)
+	row.Columns = append(row.Columns, &gossie.Column{Name: colName, Value: colVal})
+
+	err = pool.Writer().Insert(DEADLETTER_RULES_CF, &row).Run()
+	if err != nil {
+		return fmt.Errorf("Error while running cassandra insert for dead-letter rule %s", err)
+	}
+	return nil
+}
+
+func DeleteDeadLetterRule(rule *domain.DeadLetterRule) error {
+	log.Debugf("Deleting dead-letter rule %+v", rule)
+	pool, err := cassandra.ConnectionPool(BINDING_KEYSPACE)
+	if err != nil {
+		return fmt.Errorf("Error while getting cassandra connection %s", err)
+	}
+	bytes, err := json.Marshal(rule)
+	if err != nil {
+		return fmt.Errorf("Error while marshalling json %s", err)
+	}
+	lock, err := getLock(DEADLETTER_ROW)
+	if err != nil {
+		return fmt.Errorf("Error while attempting to lock %s", err)
+	}
+	defer lock.Unlock()
+
+	colName, _ := gossie.Marshal(getHash(bytes), gossie.AsciiType)
+	rowKey, _ := gossie.Marshal(DEADLETTER_ROW, gossie.AsciiType)
+	err = pool.Writer().DeleteColumns(DEADLETTER_RULES_CF, rowKey, [][]byte{colName}).Run()
+	if err != nil {
+		return fmt.Errorf("Error while running cassandra delete for dead-letter rule %s", err)
+	}
+	return nil
+}
+
+// GetDeadLetterRules returns the dead-letter rules of every service
+func GetDeadLetterRules() ([]*domain.DeadLetterRule, error) {
+	pool, err := cassandra.ConnectionPool(BINDING_KEYSPACE)
+	if err != nil {
+		return nil, fmt.Errorf("Error while getting cassandra connection %s", err)
+	}
+
+	rowKey, err := gossie.Marshal(DEADLETTER_ROW, gossie.AsciiType)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling rowKey %s", err)
+	}
+
+	ret := make([]*domain.DeadLetterRule, 0)
+	row, err := pool.Reader().Cf(DEADLETTER_RULES_CF).Get(rowKey)
+	if err != nil {
+		return nil, fmt.Errorf("Error while running cassandra query for %s %+v", DEADLETTER_RULES_CF, err)
+	}
+	if row == nil {
+		return ret, nil
+	}
+	for _, col := range row.Columns {
+		if len(col.Value) == 0 {
+			// nil column, don't bother unmarshalling
+			continue
+		}
+		r := &domain.DeadLetterRule{}
+		if err := json.Unmarshal(col.Value, r); err != nil {
+			return nil, fmt.Errorf("Error unmarshalling dead-letter rule %s", err)
+		}
+		ret = append(ret, r)
+	}
+	return ret, nil
+}
@@ -0,0 +1,115 @@
+package dao
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/cihub/seelog"
+	"time"
+
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/gossie/src/gossie"
+	"github.com/HailoOSS/service/cassandra"
+)
+
+// Records which binding service instance is the leader for each AZ
+
+const (
+	LEADERS_CF    = "binding_leaders"
+	LEADER_COLUMN = "leader"
+)
+
+// SetLeader records the leader for its AZ. The record expires after ttl unless refreshed.
+func SetLeader(leader *domain.Leader, ttl time.Duration) error {
+	pool, err := cassandra.ConnectionPool(BINDING_KEYSPACE)
+	if err != nil {
+		return fmt.Errorf("Error while getting cassandra connection %s", err)
+	}
+	// so a previous leader clearing its record can't remove ours
+	lock, err := getLock(leaderLockId(leader.AzName))
+	if err != nil {
+		return fmt.Errorf("Error while attempting to lock %s", err)
+	}
+	defer lock.Unlock()
+	bytes, err := json.Marshal(leader)
+	if err != nil {
+		return fmt.Errorf("Error while marshalling json %s", err)
+	}
+	var row gossie.Row
+	row.Key, _ = gossie.Marshal(leader.AzName, gossie.AsciiType)
+	colName, _ := gossie.Marshal(LEADER_COLUMN, gossie.AsciiType)
+	colVal, _ := gossie.Marshal(string(bytes), gossie.AsciiType)
+	row.Columns = append(row.Columns, &gossie.Column{Name: colName, Value: colVal, Ttl: int32(ttl.Seconds())})
+
+	err = pool.Writer().Insert(LEADERS_CF, &row).Run()
+	if err != nil {
+		return fmt.Errorf("Error while running cassandra insert for leader %s", err)
+	}
+	return nil
+}
+
+// GetLeader returns the current leader for the AZ or nil if there isn't one
+func GetLeader(azName string) (*domain.Leader, error) {
+	log.Debugf("Getting leader for AZ %s", azName)
+	pool, err := cassandra.ConnectionPool(BINDING_KEYSPACE)
+	if err != nil {
+		return nil, fmt.Errorf("Error while getting cassandra connection %s", err)
+	}
+
+	rowKey, err := gossie.Marshal(azName, gossie.AsciiType)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling rowKey %s", err)
+	}
+
+	row, err := pool.Reader().Cf(LEADERS_CF).Get(rowKey)
+	if err != nil {
+		return nil, fmt.Errorf("Error while running cassandra query for AZ %s %+v", azName, err)
+	}
+	if row == nil {
+		return nil, nil
+	}
+	for _, col := range row.Columns {
+		if string(col.Name) != LEADER_COLUMN || len(col.Value) == 0 {
+			continue
+		}
+		l := &domain.Leader{}
+		if err := json.Unmarshal(col.Value, l); err != nil {
+			return nil, fmt.Errorf("Error unmarshalling leader %s", err)
+		}
+		return l, nil
+	}
+	return nil, nil
+}
+
+// ClearLeader removes the record of the leader for the AZ, as long as it's still the instance
+func ClearLeader(azName string, instanceId string) error {
+	pool, err := cassandra.ConnectionPool(BINDING_KEYSPACE)
+	if err != nil {
+		return fmt.Errorf("Error while getting cassandra connection %s", err)
+	}
+	lock, err := getLock(leaderLockId(azName))
+	if err != nil {
+		return fmt.Errorf("Error while attempting to lock %s", err)
+	}
+	defer lock.Unlock()
+
+	leader, err := GetLeader(azName)
+	if err != nil {
+		return err
+	}
+	if leader == nil || leader.InstanceId != instanceId {
+		// expired or someone else has taken over
+		return nil
+	}
+	log.Debugf("Clearing leader %+v", leader)
+	rowKey, _ := gossie.Marshal(azName, gossie.AsciiType)
+	colName, _ := gossie.Marshal(LEADER_COLUMN, gossie.AsciiType)
+	err = pool.Writer().DeleteColumns(LEADERS_CF, rowKey, [][]byte{colName}).Run()
+	if err != nil {
+		return fmt.Errorf("Error while running cassandra delete for leader %s", err)
+	}
+	return nil
+}
+
+func leaderLockId(azName string) string {
+	return LEADERS_CF + "." + azName
+}
@@ -0,0 +1,80 @@
+package dao
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/HailoOSS/gossie/src/gossie"
+	"github.com/HailoOSS/service/cassandra"
+)
+
+// Records when each orphaned queue was first seen orphaned, so the grace period carries over to a new leader and
+// every instance reports the same times. There's a row per AZ with a column per queue, keyed "<vhost>|<queue>".
+
+const ORPHANS_CF = "binding_orphans"
+
+// GetOrphans returns when each orphaned queue on the AZ's cluster was first seen, keyed by "<vhost>|<queue>"
+func GetOrphans(azName string) (map[string]time.Time, error) {
+	pool, err := cassandra.ConnectionPool(BINDING_KEYSPACE)
+	if err != nil {
+		return nil, fmt.Errorf("Error while getting cassandra connection %s", err)
+	}
+
+	rowKey, err := gossie.Marshal(azName, gossie.AsciiType)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling rowKey %s", err)
+	}
+
+	ret := make(map[string]time.Time)
+	row, err := pool.Reader().Cf(ORPHANS_CF).Get(rowKey)
+	if err != nil {
+		return nil, fmt.Errorf("Error while running cassandra query for AZ %s %+v", azName, err)
+	}
+	if row == nil {
+		return ret, nil
+	}
+	for _, col := range row.Columns {
+		if len(col.Value) == 0 {
+			continue
+		}
+		secs, err := strconv.ParseInt(string(col.Value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing when queue %s was first orphaned %s", col.Name, err)
+		}
+		ret[string(col.Name)] = time.Unix(secs, 0)
+	}
+	return ret, nil
+}
+
+// SetOrphans records when the queues were first seen orphaned and forgets the ones which are gone
+func SetOrphans(azName string, firstSeen map[string]time.Time, gone []string) error {
+	pool, err := cassandra.ConnectionPool(BINDING_KEYSPACE)
+	if err != nil {
+		return fmt.Errorf("Error while getting cassandra connection %s", err)
+	}
+	rowKey, _ := gossie.Marshal(azName, gossie.AsciiType)
+
+	if len(firstSeen) > 0 {
+		row := &gossie.Row{Key: rowKey}
+		for key, t := range firstSeen {
+			colName, _ := gossie.Marshal(key, gossie.AsciiType)
+			colVal, _ := gossie.Marshal(strconv.FormatInt(t.Unix(), 10), gossie.AsciiType)
+			row.Columns = append(row.Columns, &gossie.Column{Name: colName, Value: colVal})
+		}
+		if err := pool.Writer().Insert(ORPHANS_CF, row).Run(); err != nil {
+			return fmt.Errorf("Error while running cassandra insert for orphaned queues %s", err)
+		}
+	}
+	if len(gone) > 0 {
+		cols := make([][]byte, 0, len(gone))
+		for _, key := range gone {
+			colName, _ := gossie.Marshal(key, gossie.AsciiType)
+			cols = append(cols, colName)
+		}
+		if err := pool.Writer().DeleteColumns(ORPHANS_CF, rowKey, cols).Run(); err != nil {
+			return fmt.Errorf("Error while running cassandra delete for orphaned queues %s", err)
+		}
+	}
+	return nil
+}
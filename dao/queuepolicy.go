@@ -0,0 +1,96 @@
+package dao
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/gossie/src/gossie"
+	"github.com/HailoOSS/service/cassandra"
+)
+
+// Defines crud actions for queue policy rules. Like dead-letter rules they're all read at once to set up the brokers,
+// so they're kept in a single row with a column per service and vhost.
+
+const (
+	QUEUE_POLICIES_CF = "binding_queue_policies"
+	QUEUE_POLICY_ROW  = "rules"
+)
+
+func queuePolicyColumn(service string, vhost string) []byte {
+	colName, _ := gossie.Marshal(service+"|"+vhost, gossie.AsciiType)
+	return colName
+}
+
+// CreateQueuePolicyRule creates the rule, replacing the service's existing rule for the same vhost
+func CreateQueuePolicyRule(rule *domain.QueuePolicyRule) error {
+	log.Debugf("Creating queue policy rule %+v", rule)
+	pool, err := cassandra.ConnectionPool(BINDING_KEYSPACE)
+	if err != nil {
+		return fmt.Errorf("Error while getting cassandra connection %s", err)
+	}
+	bytes, err := json.Marshal(rule)
+	if err != nil {
+		return fmt.Errorf("Error while marshalling json %s", err)
+	}
+
+	var row gossie.Row
+	row.Key, _ = gossie.Marshal(QUEUE_POLICY_ROW, gossie.AsciiType)
+	colVal, _ := gossie.Marshal(string(bytes), gossie.AsciiType)
+	row.Columns = append(row.Columns, &gossie.Column{Name: queuePolicyColumn(rule.Service, rule.Vhost), Value: colVal})
+
+	err = pool.Writer().Insert(QUEUE_POLICIES_CF, &row).Run()
+	if err != nil {
+		return fmt.Errorf("Error while running cassandra insert for queue policy rule %s", err)
+	}
+	return nil
+}
+
+func DeleteQueuePolicyRule(service string, vhost string) error {
+	log.Debugf("Deleting queue policy rule for service %s vhost %s", service, vhost)
+	pool, err := cassandra.ConnectionPool(BINDING_KEYSPACE)
+	if err != nil {
+		return fmt.Errorf("Error while getting cassandra connection %s", err)
+	}
+	rowKey, _ := gossie.Marshal(QUEUE_POLICY_ROW, gossie.AsciiType)
+	err = pool.Writer().DeleteColumns(QUEUE_POLICIES_CF, rowKey, [][]byte{queuePolicyColumn(service, vhost)}).Run()
+	if err != nil {
+		return fmt.Errorf("Error while running cassandra delete for queue policy rule %s", err)
+	}
+	return nil
+}
+
+// GetQueuePolicyRules returns the queue policy rules of every service
+func GetQueuePolicyRules() ([]*domain.QueuePolicyRule, error) {
+	pool, err := cassandra.ConnectionPool(BINDING_KEYSPACE)
+	if err != nil {
+		return nil, fmt.Errorf("Error while getting cassandra connection %s", err)
+	}
+
+	rowKey, err := gossie.Marshal(QUEUE_POLICY_ROW, gossie.AsciiType)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling rowKey %s", err)
+	}
+
+	ret := make([]*domain.QueuePolicyRule, 0)
+	row, err := pool.Reader().Cf(QUEUE_POLICIES_CF).Get(rowKey)
+	if err != nil {
+		return nil, fmt.Errorf("Error while running cassandra query for %s %+v", QUEUE_POLICIES_CF, err)
+	}
+	if row == nil {
+		return ret, nil
+	}
+	for _, col := range row.Columns {
+		if len(col.Value) == 0 {
+			// nil column, don't bother unmarshalling
+			continue
+		}
+		r := &domain.QueuePolicyRule{}
+		if err := json.Unmarshal(col.Value, r); err != nil {
+			return nil, fmt.Errorf("Error unmarshalling queue policy rule %s", err)
+		}
+		ret = append(ret, r)
+	}
+	return ret, nil
+}
@@ -1,15 +1,18 @@
 package domain
 
 import (
+	"fmt"
 	instances "github.com/HailoOSS/discovery-service/proto/instances"
 	serviceup "github.com/HailoOSS/discovery-service/proto/serviceup"
 	"github.com/HailoOSS/platform/raven"
 	"strconv"
+	"time"
 )
 
 type RabbitExchange struct {
 	Hostname string // hostname of broker it lives on
 	Hostport int
+	Vhost    string
 	Name     string // name of exchange
 	Xtype    string
 	Options  map[string]interface{}
@@ -18,6 +21,7 @@ type RabbitExchange struct {
 type RabbitQueue struct {
 	Hostname string
 	Hostport int
+	Vhost    string
 	Name     string
 	Options  map[string]interface{}
 }
@@ -94,16 +98,88 @@ type Rule struct {
 	Service string
 	Version string // technically this is a number but string is more flexible and comparison operations work the same
 	Weight  int32
+	Vhost   string `json:",omitempty"` // empty applies to every vhost
 }
 
 func (this *Rule) IsApplicable(s *Service) bool {
-	return this.Service == s.Service && this.Version == s.Version
+	return this.Service == s.Service && this.Version == s.Version && (this.Vhost == "" || this.Vhost == s.GetVhost())
 }
 
 func (this *Rule) GetRuleMap() map[string]interface{} {
 	return map[string]interface{}{"service": this.Service, "x-weight": float64(this.Weight)} // cast to float because json numbers are floats
 }
 
+// A DeadLetterRule gives a service its own queue of the dead-lettered messages for it
+type DeadLetterRule struct {
+	Service string
+	Vhost   string `json:",omitempty"` // empty applies to every vhost
+}
+
+func (this *DeadLetterRule) IsApplicable(vhost string) bool {
+	return this.Vhost == "" || this.Vhost == vhost
+}
+
+// A QueuePolicyRule sets the TTL, max length, overflow behaviour and HA mode of a service's instance queues
+type QueuePolicyRule struct {
+	Service    string
+	Vhost      string `json:",omitempty"` // empty applies to every vhost
+	MessageTTL int64  `json:",omitempty"` // milliseconds
+	MaxLength  int64  `json:",omitempty"`
+	Overflow   string `json:",omitempty"` // drop-head, reject-publish or reject-publish-dlx
+	HAMode     string `json:",omitempty"` // all or exactly
+	HAParams   int64  `json:",omitempty"` // how many nodes with exactly
+	DeadLetter bool   `json:",omitempty"` // dead-letter to the dead-letter exchange
+}
+
+func (this *QueuePolicyRule) IsApplicable(vhost string) bool {
+	return this.Vhost == "" || this.Vhost == vhost
+}
+
+// Validate returns what's wrong with the rule, if anything
+func (this *QueuePolicyRule) Validate() error {
+	switch {
+	case this.Service == "":
+		return fmt.Errorf("Service is required")
+	case this.MessageTTL < 0 || this.MaxLength < 0 || this.HAParams < 0:
+		return fmt.Errorf("Message TTL, max length and HA params can't be negative")
+	case this.Overflow != "" && this.Overflow != "drop-head" && this.Overflow != "reject-publish" && this.Overflow != "reject-publish-dlx":
+		return fmt.Errorf("Invalid overflow %s", this.Overflow)
+	case this.HAMode != "" && this.HAMode != "all" && this.HAMode != "exactly":
+		return fmt.Errorf("Invalid HA mode %s", this.HAMode)
+	case this.HAMode == "exactly" && this.HAParams == 0:
+		return fmt.Errorf("HA mode exactly needs the number of nodes in HA params")
+	case this.HAMode != "exactly" && this.HAParams != 0:
+		return fmt.Errorf("HA params are only used with HA mode exactly")
+	case this.MessageTTL == 0 && this.MaxLength == 0 && this.Overflow == "" && this.HAMode == "" && !this.DeadLetter:
+		return fmt.Errorf("Rule doesn't set anything")
+	}
+	return nil
+}
+
+// GetDefinition returns the definition of the rabbit policy for the rule, dead-lettering to deadLetterExchange
+func (this *QueuePolicyRule) GetDefinition(deadLetterExchange string) map[string]interface{} {
+	res := make(map[string]interface{})
+	if this.MessageTTL > 0 {
+		res["message-ttl"] = this.MessageTTL
+	}
+	if this.MaxLength > 0 {
+		res["max-length"] = this.MaxLength
+	}
+	if this.Overflow != "" {
+		res["overflow"] = this.Overflow
+	}
+	if this.HAMode != "" {
+		res["ha-mode"] = this.HAMode
+	}
+	if this.HAParams > 0 {
+		res["ha-params"] = this.HAParams
+	}
+	if this.DeadLetter {
+		res["dead-letter-exchange"] = deadLetterExchange
+	}
+	return res
+}
+
 func (this *BindingDef) GetDestTypeCode() string {
 	if this.DestinationType != "" && len(this.DestinationType) > 0 {
 		return this.DestinationType[0:1]
@@ -113,14 +189,34 @@ func (this *BindingDef) GetDestTypeCode() string {
 
 }
 
+// A Leader is the binding service instance running the periodic reconciliation for an AZ
+type Leader struct {
+	InstanceId string
+	Hostname   string
+	AzName     string
+	Since      time.Time
+}
+
 type Service struct {
 	Service       string
 	Version       string
 	Instance      string
 	AzName        string
+	Vhost         string // vhost the instance's queue lives in, empty until it's known
 	Subscriptions []string
 }
 
+// GetVhost returns the service's vhost, or the default vhost if it isn't known
+func (this *Service) GetVhost() string {
+	if this.Vhost == "" {
+		return DEFAULT_VHOST
+	}
+	return this.Vhost
+}
+
+// the vhost used when none is given
+const DEFAULT_VHOST = "/"
+
 type DestinationTypeL string
 
 const (
@@ -144,9 +240,9 @@ func ServiceFromServiceupProto(i *serviceup.Request) *Service {
 }
 
 func BindingDefFromService(s *Service) *BindingDef {
-	return &BindingDef{Source: raven.EXCHANGE, Vhost: "/", Destination: s.Instance, DestinationType: string(QUEUE), RoutingKey: s.Service, Arguments: map[string]interface{}{"x-match": "all", "service": s.Service}}
+	return &BindingDef{Source: raven.EXCHANGE, Vhost: s.GetVhost(), Destination: s.Instance, DestinationType: string(QUEUE), RoutingKey: s.Service, Arguments: map[string]interface{}{"x-match": "all", "service": s.Service}}
 }
 
 func ExchangeBindingDefFromService(s *Service, azName string) *BindingDef {
-	return &BindingDef{Source: raven.EXCHANGE, Vhost: "/", Destination: azName, DestinationType: string(EXCHANGE), RoutingKey: s.Service, Arguments: map[string]interface{}{"x-match": "all", "x-nofed": "yes", "service": s.Service}}
+	return &BindingDef{Source: raven.EXCHANGE, Vhost: s.GetVhost(), Destination: azName, DestinationType: string(EXCHANGE), RoutingKey: s.Service, Arguments: map[string]interface{}{"x-match": "all", "x-nofed": "yes", "service": s.Service}}
 }
@@ -66,3 +66,44 @@ func TestServiceToExchangeBindingDef(t *testing.T) {
 		t.Error("'x-nofed' incorrect ", b.Arguments["x-nofed"])
 	}
 }
+
+func TestRuleIsApplicableInVhost(t *testing.T) {
+	s := &Service{Service: "com.HailoOSS.service.foobar", Version: "201306271500", Vhost: "staging"}
+	if !(&Rule{Service: s.Service, Version: s.Version}).IsApplicable(s) {
+		t.Error("Rule without a vhost should apply in every vhost")
+	}
+	if !(&Rule{Service: s.Service, Version: s.Version, Vhost: "staging"}).IsApplicable(s) {
+		t.Error("Rule should apply in its vhost")
+	}
+	if (&Rule{Service: s.Service, Version: s.Version, Vhost: "/"}).IsApplicable(s) {
+		t.Error("Rule shouldn't apply in other vhosts")
+	}
+	if b := BindingDefFromService(s); b.Vhost != "staging" {
+		t.Error("Vhost incorrect ", b.Vhost)
+	}
+}
+
+func TestQueuePolicyRule(t *testing.T) {
+	invalid := []*QueuePolicyRule{
+		{MaxLength: 10},
+		{Service: "com.HailoOSS.service.foobar"},
+		{Service: "com.HailoOSS.service.foobar", MaxLength: -1},
+		{Service: "com.HailoOSS.service.foobar", Overflow: "drop-tail"},
+		{Service: "com.HailoOSS.service.foobar", HAMode: "exactly"},
+		{Service: "com.HailoOSS.service.foobar", HAMode: "all", HAParams: 2},
+	}
+	for _, r := range invalid {
+		if r.Validate() == nil {
+			t.Errorf("Rule %+v should be invalid", r)
+		}
+	}
+
+	r := &QueuePolicyRule{Service: "com.HailoOSS.service.foobar", MaxLength: 1000, Overflow: "reject-publish", HAMode: "exactly", HAParams: 2, DeadLetter: true}
+	if err := r.Validate(); err != nil {
+		t.Error("Rule should be valid ", err)
+	}
+	def := r.GetDefinition("h2o.deadletter")
+	if len(def) != 5 || def["max-length"] != int64(1000) || def["overflow"] != "reject-publish" || def["ha-mode"] != "exactly" || def["ha-params"] != int64(2) || def["dead-letter-exchange"] != "h2o.deadletter" {
+		t.Error("Wrong definition ", def)
+	}
+}
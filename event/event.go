@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/cihub/seelog"
@@ -16,6 +17,9 @@ import (
 const (
 	CreateRule = "CREATED"
 	DeleteRule = "DELETED"
+	PurgeQueue = "PURGED"
+	Replay     = "REPLAYED"
+	Unroutable = "UNROUTABLE"
 	nsqTopic   = "platform.events"
 )
 
@@ -46,7 +50,87 @@ func generatePseudoRand() string {
 	return string(bytes)
 }
 
-func PubRuleChange(service, version, action, user string, weight int32) {
+func PubRuleChange(service, version, vhost, action, user string, weight int32) {
+	publish(map[string]string{
+		"ServiceName":    service,
+		"ServiceVersion": version,
+		"Vhost":          vhost,
+		"AzName":         azName,
+		"Hostname":       hostname,
+		"Action":         action,
+		"Weight":         strconv.Itoa(int(weight)),
+		"UserId":         user,
+	})
+}
+
+// PubQueuePurge records that someone purged a queue on the cluster in queueAz
+func PubQueuePurge(queueAz, vhost, queue string, messages int, user string) {
+	publish(map[string]string{
+		"Queue":       queue,
+		"Vhost":       vhost,
+		"QueueAzName": queueAz,
+		"Messages":    strconv.Itoa(messages),
+		"AzName":      azName,
+		"Hostname":    hostname,
+		"Action":      PurgeQueue,
+		"UserId":      user,
+	})
+}
+
+// PubDeadLetterRuleChange records that someone created or deleted a service's dead-letter queue rule
+func PubDeadLetterRuleChange(service, vhost, action, user string) {
+	publish(map[string]string{
+		"ServiceName": service,
+		"Vhost":       vhost,
+		"DeadLetter":  "true",
+		"AzName":      azName,
+		"Hostname":    hostname,
+		"Action":      action,
+		"UserId":      user,
+	})
+}
+
+// PubQueuePolicyChange records that someone set or deleted the policy of a service's instance queues
+func PubQueuePolicyChange(service, vhost, action, user, definition string) {
+	publish(map[string]string{
+		"ServiceName": service,
+		"Vhost":       vhost,
+		"QueuePolicy": definition,
+		"AzName":      azName,
+		"Hostname":    hostname,
+		"Action":      action,
+		"UserId":      user,
+	})
+}
+
+// PubDeadLetterReplay records that someone replayed messages from a dead-letter queue on the cluster in queueAz
+func PubDeadLetterReplay(queueAz, vhost, queue string, messages int, user string) {
+	publish(map[string]string{
+		"Queue":       queue,
+		"Vhost":       vhost,
+		"QueueAzName": queueAz,
+		"Messages":    strconv.Itoa(messages),
+		"AzName":      azName,
+		"Hostname":    hostname,
+		"Action":      Replay,
+		"UserId":      user,
+	})
+}
+
+// PubUnroutable records the services receiving messages h2o couldn't route on the cluster in queueAz
+func PubUnroutable(queueAz, vhost string, services []string, messages int) {
+	publish(map[string]string{
+		"Services":    strings.Join(services, ","),
+		"Vhost":       vhost,
+		"QueueAzName": queueAz,
+		"Messages":    strconv.Itoa(messages),
+		"AzName":      azName,
+		"Hostname":    hostname,
+		"Action":      Unroutable,
+	})
+}
+
+func publish(details map[string]string) {
 	var uuid string
 	u4, err := gouuid.NewV4()
 	if err != nil {
@@ -59,15 +143,7 @@ func PubRuleChange(service, version, action, user string, weight int32) {
 		"id":        uuid,
 		"timestamp": strconv.Itoa(int(time.Now().Unix())),
 		"type":      "com.HailoOSS.kernel.binding.event",
-		"details": map[string]string{
-			"ServiceName":    service,
-			"ServiceVersion": version,
-			"AzName":         azName,
-			"Hostname":       hostname,
-			"Action":         action,
-			"Weight":         strconv.Itoa(int(weight)),
-			"UserId":         user,
-		},
+		"details":   details,
 	}
 
 	bytes, err := json.Marshal(event)
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/binding"
+	"github.com/HailoOSS/binding-service/dao"
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/event"
+	rule "github.com/HailoOSS/binding-service/proto"
+	createdeadletterrule "github.com/HailoOSS/binding-service/proto/createdeadletterrule"
+	deadletters "github.com/HailoOSS/binding-service/proto/deadletters"
+	deletedeadletterrule "github.com/HailoOSS/binding-service/proto/deletedeadletterrule"
+	listdeadletterrules "github.com/HailoOSS/binding-service/proto/listdeadletterrules"
+	replaydeadletters "github.com/HailoOSS/binding-service/proto/replaydeadletters"
+	"github.com/HailoOSS/binding-service/rabbit"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+	"github.com/HailoOSS/protobuf/proto"
+)
+
+func CreateDeadLetterRuleHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &createdeadletterrule.Request{}
+	err := req.Unmarshal(request)
+	if err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.createdeadletterrule", err.Error())
+	}
+	ruleReq := request.GetRule()
+	if ruleReq.GetService() == "" {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.createdeadletterrule", "Service is required")
+	}
+	err = dao.CreateDeadLetterRule(&domain.DeadLetterRule{Service: ruleReq.GetService(), Vhost: ruleReq.GetVhost()})
+	if err != nil {
+		log.Errorf("Error creating dead-letter rule %+v", err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.createdeadletterrule", err.Error())
+	}
+
+	event.PubDeadLetterRuleChange(ruleReq.GetService(), ruleReq.GetVhost(), event.CreateRule, getUser(req))
+
+	// the leader creates the queue and binds it the next time it reconciles
+	return &createdeadletterrule.Response{Ok: proto.Bool(true)}, nil
+}
+
+func DeleteDeadLetterRuleHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &deletedeadletterrule.Request{}
+	err := req.Unmarshal(request)
+	if err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.deletedeadletterrule", err.Error())
+	}
+	ruleReq := request.GetRule()
+	err = dao.DeleteDeadLetterRule(&domain.DeadLetterRule{Service: ruleReq.GetService(), Vhost: ruleReq.GetVhost()})
+	if err != nil {
+		log.Errorf("Error deleting dead-letter rule %+v", err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.deletedeadletterrule", err.Error())
+	}
+
+	event.PubDeadLetterRuleChange(ruleReq.GetService(), ruleReq.GetVhost(), event.DeleteRule, getUser(req))
+
+	// the leader unbinds the queue the next time it reconciles, the queue is left to be inspected and deleted
+	return &deletedeadletterrule.Response{Ok: proto.Bool(true)}, nil
+}
+
+func ListDeadLetterRulesHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &listdeadletterrules.Request{}
+	err := req.Unmarshal(request)
+	if err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.listdeadletterrules", err.Error())
+	}
+	rules, err := dao.GetDeadLetterRules()
+	if err != nil {
+		log.Errorf("Error listing dead-letter rules %+v", err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.listdeadletterrules", err.Error())
+	}
+	ret := make([]*rule.DeadLetterRule, 0)
+	for _, r := range rules {
+		if request.GetService() != "" && r.Service != request.GetService() {
+			continue
+		}
+		dr := &rule.DeadLetterRule{Service: proto.String(r.Service)}
+		if r.Vhost != "" {
+			dr.Vhost = proto.String(r.Vhost)
+		}
+		ret = append(ret, dr)
+	}
+	return &listdeadletterrules.Response{Rules: ret}, nil
+}
+
+// Returns the depth of a dead-letter queue and some of its messages
+func DeadLettersHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &deadletters.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.deadletters", err.Error())
+	}
+	payloadBytes := int(request.GetPayloadBytes())
+	if payloadBytes <= 0 {
+		payloadBytes = DEFAULT_PAYLOAD_BYTES
+	}
+
+	in, err := binding.ListDeadLetters(context.Background(), request.GetAzname(), request.GetVhost(), request.GetService(), int(request.GetCount()))
+	if rabbit.IsNotFound(err) {
+		return nil, errors.NotFound("com.HailoOSS.kernel.binding.deadletters", err.Error())
+	} else if err != nil {
+		log.Errorf("Error listing dead-letters %+v", err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.deadletters", err.Error())
+	}
+
+	rsp := &deadletters.Response{
+		Azname:   proto.String(in.AzName),
+		Vhost:    proto.String(in.Queue.Vhost),
+		Queue:    proto.String(in.Queue.Name),
+		Messages: proto.Int32(int32(in.Queue.Messages)),
+	}
+	for _, m := range in.Messages {
+		payload, truncated := samplePayload(m, payloadBytes)
+		msg := &deadletters.Response_Message{
+			Exchange:     proto.String(m.Exchange),
+			RoutingKey:   proto.String(m.Routing_key),
+			PayloadBytes: proto.Int32(int32(m.Payload_bytes)),
+			Payload:      proto.String(payload),
+			Truncated:    proto.Bool(truncated),
+		}
+		if service, ok := m.Properties.Headers["service"]; ok {
+			msg.Service = proto.String(fmt.Sprint(service))
+		}
+		if m.Payload_encoding != "" {
+			msg.PayloadEncoding = proto.String(m.Payload_encoding)
+		}
+		if m.Properties.Message_id != "" {
+			msg.MessageId = proto.String(m.Properties.Message_id)
+		}
+		if m.Properties.Reply_to != "" {
+			msg.ReplyTo = proto.String(m.Properties.Reply_to)
+		}
+		if len(m.Properties.Headers) > 0 {
+			if b, err := json.Marshal(m.Properties.Headers); err == nil {
+				msg.Headers = proto.String(string(b))
+			}
+		}
+		rsp.Samples = append(rsp.Samples, msg)
+	}
+	return rsp, nil
+}
+
+// Publishes messages from a dead-letter queue to h2o again, every replay is published as an event
+func ReplayDeadLettersHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &replaydeadletters.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.replaydeadletters", err.Error())
+	}
+
+	res, err := binding.ReplayDeadLetters(context.Background(), request.GetAzname(), request.GetVhost(), request.GetService(), int(request.GetCount()))
+	if res != nil && res.Replayed+res.Unrouted > 0 {
+		event.PubDeadLetterReplay(res.AzName, res.Vhost, res.Queue, res.Replayed+res.Unrouted, getUser(req))
+	}
+	if rabbit.IsNotFound(err) {
+		return nil, errors.NotFound("com.HailoOSS.kernel.binding.replaydeadletters", err.Error())
+	} else if err != nil {
+		log.Errorf("Error replaying dead-letters %+v", err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.replaydeadletters", err.Error())
+	}
+
+	return &replaydeadletters.Response{
+		Azname:   proto.String(res.AzName),
+		Vhost:    proto.String(res.Vhost),
+		Queue:    proto.String(res.Queue),
+		Replayed: proto.Int32(int32(res.Replayed)),
+		Unrouted: proto.Int32(int32(res.Unrouted)),
+		Failed:   proto.Int32(int32(res.Failed)),
+	}, nil
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/binding"
+	"github.com/HailoOSS/binding-service/domain"
+	drift "github.com/HailoOSS/binding-service/proto/drift"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+	"github.com/HailoOSS/protobuf/proto"
+)
+
+// Compares the bindings every cluster should have against the ones it does
+func DriftHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &drift.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.drift", err.Error())
+	}
+	report, err := binding.GetDrift(context.Background(), request.GetAzname(), request.GetService())
+	if err != nil {
+		log.Errorf("Error retrieving drift %+v", err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.drift", err.Error())
+	}
+
+	rsp := &drift.Response{
+		Missing:    proto.Int32(int32(report.Missing)),
+		Extra:      proto.Int32(int32(report.Extra)),
+		Mismatched: proto.Int32(int32(report.Mismatched)),
+	}
+	for _, d := range report.Differences {
+		diff := &drift.Difference{
+			Azname:   proto.String(d.AzName),
+			Kind:     proto.String(d.Kind),
+			Problem:  proto.String(d.Problem),
+			Expected: driftBinding(d.Expected),
+			Actual:   driftBinding(d.Actual),
+		}
+		if d.Service != "" {
+			diff.Service = proto.String(d.Service)
+		}
+		rsp.Differences = append(rsp.Differences, diff)
+	}
+	for _, c := range report.Clusters {
+		cluster := &drift.Cluster{
+			Azname:     proto.String(c.AzName),
+			Hostname:   proto.String(c.Host),
+			Missing:    proto.Int32(int32(c.Missing)),
+			Extra:      proto.Int32(int32(c.Extra)),
+			Mismatched: proto.Int32(int32(c.Mismatched)),
+		}
+		if c.Err != nil {
+			cluster.Error = proto.String(c.Err.Error())
+		}
+		rsp.Clusters = append(rsp.Clusters, cluster)
+	}
+	return rsp, nil
+}
+
+func driftBinding(b *domain.BindingDef) *drift.Binding {
+	if b == nil {
+		return nil
+	}
+	res := &drift.Binding{
+		Source:          proto.String(b.Source),
+		Vhost:           proto.String(b.Vhost),
+		Destination:     proto.String(b.Destination),
+		DestinationType: proto.String(b.DestinationType),
+		RoutingKey:      proto.String(b.RoutingKey),
+	}
+	if len(b.Arguments) > 0 {
+		if args, err := json.Marshal(b.Arguments); err == nil {
+			res.Arguments = proto.String(string(args))
+		}
+	}
+	return res
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"sort"
+
+	"github.com/HailoOSS/binding-service/binding"
+	federationlinks "github.com/HailoOSS/binding-service/proto/federationlinks"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+	"github.com/HailoOSS/protobuf/proto"
+)
+
+// Returns the last known state of the federation links on each cluster
+func FederationLinksHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &federationlinks.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.federationlinks", err.Error())
+	}
+	status := binding.GetFederationStatus(request.GetAzname())
+
+	rsp := &federationlinks.Response{}
+	if !status.Checked.IsZero() {
+		rsp.Checked = proto.Int64(status.Checked.Unix())
+	}
+	for _, l := range status.Links {
+		link := &federationlinks.Response_Link{
+			Azname:   proto.String(l.AzName),
+			Vhost:    proto.String(l.Vhost),
+			Upstream: proto.String(l.Upstream),
+			Exchange: proto.String(l.Exchange),
+			Status:   proto.String(l.Status),
+			Since:    proto.Int64(l.Since.Unix()),
+		}
+		if l.UpstreamAz != "" {
+			link.UpstreamAzname = proto.String(l.UpstreamAz)
+		}
+		if l.Error != "" {
+			link.Error = proto.String(l.Error)
+		}
+		rsp.Links = append(rsp.Links, link)
+	}
+	azs := make([]string, 0, len(status.Errs))
+	for az := range status.Errs {
+		azs = append(azs, az)
+	}
+	sort.Strings(azs)
+	for _, az := range azs {
+		rsp.Errors = append(rsp.Errors, &federationlinks.Response_ClusterError{
+			Azname: proto.String(az),
+			Error:  proto.String(status.Errs[az].Error()),
+		})
+	}
+	return rsp, nil
+}
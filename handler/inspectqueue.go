@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"unicode/utf8"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/binding"
+	inspectqueue "github.com/HailoOSS/binding-service/proto/inspectqueue"
+	"github.com/HailoOSS/binding-service/rabbit"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+	"github.com/HailoOSS/protobuf/proto"
+)
+
+const DEFAULT_PAYLOAD_BYTES = 1024
+
+// Returns a queue's depth, rates, consumers and bindings along with some of its messages, to debug stuck queues
+func InspectQueueHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &inspectqueue.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.inspectqueue", err.Error())
+	}
+	if request.GetQueue() == "" {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.inspectqueue", "Queue is required")
+	}
+	payloadBytes := int(request.GetPayloadBytes())
+	if payloadBytes <= 0 {
+		payloadBytes = DEFAULT_PAYLOAD_BYTES
+	}
+
+	in, err := binding.InspectQueue(context.Background(), request.GetAzname(), request.GetVhost(), request.GetQueue(), int(request.GetSamples()))
+	if rabbit.IsNotFound(err) {
+		return nil, errors.NotFound("com.HailoOSS.kernel.binding.inspectqueue", err.Error())
+	} else if err != nil {
+		log.Errorf("Error inspecting queue %s %+v", request.GetQueue(), err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.inspectqueue", err.Error())
+	}
+
+	q := in.Queue
+	rsp := &inspectqueue.Response{
+		Azname:                 proto.String(in.AzName),
+		Hostname:               proto.String(in.Host),
+		Vhost:                  proto.String(q.Vhost),
+		Queue:                  proto.String(q.Name),
+		Messages:               proto.Int32(int32(q.Messages)),
+		MessagesReady:          proto.Int32(int32(q.Messages_ready)),
+		MessagesUnacknowledged: proto.Int32(int32(q.Messages_unacknowledged)),
+		PublishRate:            proto.Float64(q.Message_stats.Publish_details.Rate),
+		DeliverRate:            proto.Float64(q.Message_stats.Deliver_get_details.Rate),
+		Consumers:              proto.Int32(int32(q.Consumers)),
+		Durable:                proto.Bool(q.Durable),
+		AutoDelete:             proto.Bool(q.Auto_delete),
+		Arguments:              jsonString(q.Arguments),
+	}
+	if q.Node != "" {
+		rsp.Node = proto.String(q.Node)
+	}
+	if q.Idle_since != "" {
+		rsp.IdleSince = proto.String(q.Idle_since)
+	}
+	if q.Policy != "" {
+		rsp.Policy = proto.String(q.Policy)
+	}
+	for _, b := range in.Bindings {
+		rsp.Bindings = append(rsp.Bindings, &inspectqueue.Response_Binding{
+			Source:     proto.String(b.Source),
+			RoutingKey: proto.String(b.RoutingKey),
+			Arguments:  jsonString(b.Arguments),
+		})
+	}
+	for _, m := range in.Messages {
+		payload, truncated := samplePayload(m, payloadBytes)
+		msg := &inspectqueue.Response_Message{
+			Exchange:     proto.String(m.Exchange),
+			RoutingKey:   proto.String(m.Routing_key),
+			Redelivered:  proto.Bool(m.Redelivered),
+			PayloadBytes: proto.Int32(int32(m.Payload_bytes)),
+			Payload:      proto.String(payload),
+			Truncated:    proto.Bool(truncated),
+		}
+		if m.Payload_encoding != "" {
+			msg.PayloadEncoding = proto.String(m.Payload_encoding)
+		}
+		if m.Properties.Message_id != "" {
+			msg.MessageId = proto.String(m.Properties.Message_id)
+		}
+		if m.Properties.Reply_to != "" {
+			msg.ReplyTo = proto.String(m.Properties.Reply_to)
+		}
+		if m.Properties.Content_type != "" {
+			msg.ContentType = proto.String(m.Properties.Content_type)
+		}
+		if len(m.Properties.Headers) > 0 {
+			if b, err := json.Marshal(m.Properties.Headers); err == nil {
+				msg.Headers = proto.String(string(b))
+			}
+		}
+		rsp.Samples = append(rsp.Samples, msg)
+	}
+	return rsp, nil
+}
+
+// samplePayload returns the message's payload cut down to n bytes, and whether it's been truncated
+func samplePayload(m *rabbit.Message, n int) (string, bool) {
+	payload, truncated := truncatePayload(m.Payload, n)
+	if m.Payload_encoding == "string" && len(m.Payload) < m.Payload_bytes {
+		// rabbit truncated it already
+		truncated = true
+	}
+	return payload, truncated
+}
+
+// truncatePayload cuts the payload down to at most n bytes, without splitting a UTF-8 character
+func truncatePayload(payload string, n int) (string, bool) {
+	if len(payload) <= n {
+		return payload, false
+	}
+	for n > 0 && !utf8.RuneStart(payload[n]) {
+		n--
+	}
+	return payload[:n], true
+}
+
+func jsonString(args map[string]interface{}) *string {
+	if len(args) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(args)
+	if err != nil {
+		return nil
+	}
+	return proto.String(string(b))
+}
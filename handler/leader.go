@@ -0,0 +1,31 @@
+package handler
+
+import (
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/binding"
+	leader "github.com/HailoOSS/binding-service/proto/leader"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+	"github.com/HailoOSS/protobuf/proto"
+)
+
+// Returns the binding service instance currently running the periodic reconciliation for an AZ
+func LeaderHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &leader.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.leader", err.Error())
+	}
+	l, err := binding.GetLeader(request.GetAzname())
+	if err != nil {
+		log.Errorf("Error retrieving leader %+v", err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.leader", err.Error())
+	}
+	rsp := &leader.Response{IsLeader: proto.Bool(binding.IsLeader())}
+	if l != nil {
+		rsp.InstanceId = proto.String(l.InstanceId)
+		rsp.Hostname = proto.String(l.Hostname)
+		rsp.Azname = proto.String(l.AzName)
+		rsp.Since = proto.Int64(l.Since.Unix())
+	}
+	return rsp, nil
+}
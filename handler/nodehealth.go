@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"sort"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/binding"
+	nodehealth "github.com/HailoOSS/binding-service/proto/nodehealth"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+	"github.com/HailoOSS/protobuf/proto"
+)
+
+// Returns the health of the rabbit nodes as seen by this binding service instance
+func NodeHealthHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &nodehealth.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.nodehealth", err.Error())
+	}
+	health, err := binding.GetNodeHealth(request.GetAzname())
+	if err != nil {
+		log.Errorf("Error retrieving node health %+v", err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.nodehealth", err.Error())
+	}
+	azs := make([]string, 0, len(health))
+	for az := range health {
+		azs = append(azs, az)
+	}
+	sort.Strings(azs)
+
+	rsp := &nodehealth.Response{}
+	for _, az := range azs {
+		for _, n := range health[az] {
+			node := &nodehealth.Response_Node{
+				Hostname: proto.String(n.Host),
+				Azname:   proto.String(az),
+				Healthy:  proto.Bool(n.Healthy),
+				Failures: proto.Int32(int32(n.Failures)),
+			}
+			if !n.LastFailure.IsZero() {
+				node.LastFailure = proto.Int64(n.LastFailure.Unix())
+			}
+			if !n.LastSuccess.IsZero() {
+				node.LastSuccess = proto.Int64(n.LastSuccess.Unix())
+			}
+			if n.LastError != "" {
+				node.LastError = proto.String(n.LastError)
+			}
+			rsp.Nodes = append(rsp.Nodes, node)
+		}
+	}
+	return rsp, nil
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"context"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/binding"
+	orphanedqueues "github.com/HailoOSS/binding-service/proto/orphanedqueues"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+	"github.com/HailoOSS/protobuf/proto"
+)
+
+// Returns the orphaned queues on this AZ's cluster and whether they'd be collected, without changing anything
+func OrphanedQueuesHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &orphanedqueues.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.orphanedqueues", err.Error())
+	}
+	report, err := binding.FindOrphanedQueues(context.Background())
+	if err != nil {
+		log.Errorf("Error finding orphaned queues %+v", err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.orphanedqueues", err.Error())
+	}
+
+	rsp := &orphanedqueues.Response{Azname: proto.String(report.AzName)}
+	for _, q := range report.Queues {
+		rsp.Queues = append(rsp.Queues, &orphanedqueues.Response_Queue{
+			Vhost:     proto.String(q.Vhost),
+			Name:      proto.String(q.Name),
+			Messages:  proto.Int32(int32(q.Messages)),
+			FirstSeen: proto.Int64(q.FirstSeen.Unix()),
+			Action:    proto.String(q.Action),
+		})
+	}
+	return rsp, nil
+}
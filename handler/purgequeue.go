@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"context"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/binding"
+	"github.com/HailoOSS/binding-service/event"
+	purgequeue "github.com/HailoOSS/binding-service/proto/purgequeue"
+	"github.com/HailoOSS/binding-service/rabbit"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+	"github.com/HailoOSS/protobuf/proto"
+)
+
+// Deletes all the messages in a queue. The first request returns a token, the queue is only purged when it's sent
+// back, and every purge is published as an event.
+func PurgeQueueHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &purgequeue.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.purgequeue", err.Error())
+	}
+	if request.GetQueue() == "" {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.purgequeue", "Queue is required")
+	}
+
+	res, err := binding.PurgeQueue(context.Background(), request.GetAzname(), request.GetVhost(), request.GetQueue(), request.GetToken())
+	switch {
+	case err == binding.ErrInvalidPurgeToken:
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.purgequeue", err.Error())
+	case rabbit.IsNotFound(err):
+		return nil, errors.NotFound("com.HailoOSS.kernel.binding.purgequeue", err.Error())
+	case err != nil:
+		log.Errorf("Error purging queue %s %+v", request.GetQueue(), err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.purgequeue", err.Error())
+	}
+	if res.Purged {
+		event.PubQueuePurge(res.AzName, res.Vhost, request.GetQueue(), res.Messages, getUser(req))
+	}
+
+	rsp := &purgequeue.Response{
+		Purged:   proto.Bool(res.Purged),
+		Messages: proto.Int32(int32(res.Messages)),
+		Azname:   proto.String(res.AzName),
+		Vhost:    proto.String(res.Vhost),
+	}
+	if res.Token != "" {
+		rsp.Token = proto.String(res.Token)
+	}
+	return rsp, nil
+}
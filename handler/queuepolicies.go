@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/binding"
+	"github.com/HailoOSS/binding-service/dao"
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/event"
+	rule "github.com/HailoOSS/binding-service/proto"
+	createqueuepolicy "github.com/HailoOSS/binding-service/proto/createqueuepolicy"
+	deletequeuepolicy "github.com/HailoOSS/binding-service/proto/deletequeuepolicy"
+	listqueuepolicies "github.com/HailoOSS/binding-service/proto/listqueuepolicies"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+	"github.com/HailoOSS/protobuf/proto"
+)
+
+func CreateQueuePolicyHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &createqueuepolicy.Request{}
+	err := req.Unmarshal(request)
+	if err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.createqueuepolicy", err.Error())
+	}
+	ruleReq := request.GetRule()
+	r := &domain.QueuePolicyRule{
+		Service:    ruleReq.GetService(),
+		Vhost:      ruleReq.GetVhost(),
+		MessageTTL: ruleReq.GetMessageTtl(),
+		MaxLength:  ruleReq.GetMaxLength(),
+		Overflow:   ruleReq.GetOverflow(),
+		HAMode:     ruleReq.GetHaMode(),
+		HAParams:   ruleReq.GetHaParams(),
+		DeadLetter: ruleReq.GetDeadLetter(),
+	}
+	if err := r.Validate(); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.createqueuepolicy", err.Error())
+	}
+	err = dao.CreateQueuePolicyRule(r)
+	if err != nil {
+		log.Errorf("Error creating queue policy rule %+v", err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.createqueuepolicy", err.Error())
+	}
+
+	definition, _ := json.Marshal(r.GetDefinition(binding.DEADLETTER_EXCHANGE))
+	event.PubQueuePolicyChange(r.Service, r.Vhost, event.CreateRule, getUser(req), string(definition))
+
+	// the leader sets the policy the next time it reconciles
+	return &createqueuepolicy.Response{Ok: proto.Bool(true)}, nil
+}
+
+func DeleteQueuePolicyHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &deletequeuepolicy.Request{}
+	err := req.Unmarshal(request)
+	if err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.deletequeuepolicy", err.Error())
+	}
+	err = dao.DeleteQueuePolicyRule(request.GetService(), request.GetVhost())
+	if err != nil {
+		log.Errorf("Error deleting queue policy rule %+v", err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.deletequeuepolicy", err.Error())
+	}
+
+	event.PubQueuePolicyChange(request.GetService(), request.GetVhost(), event.DeleteRule, getUser(req), "")
+
+	// the leader removes the policy the next time it reconciles
+	return &deletequeuepolicy.Response{Ok: proto.Bool(true)}, nil
+}
+
+func ListQueuePoliciesHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &listqueuepolicies.Request{}
+	err := req.Unmarshal(request)
+	if err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.listqueuepolicies", err.Error())
+	}
+	rules, err := dao.GetQueuePolicyRules()
+	if err != nil {
+		log.Errorf("Error listing queue policy rules %+v", err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.listqueuepolicies", err.Error())
+	}
+	ret := make([]*rule.QueuePolicyRule, 0)
+	for _, r := range rules {
+		if request.GetService() != "" && r.Service != request.GetService() {
+			continue
+		}
+		qr := &rule.QueuePolicyRule{Service: proto.String(r.Service)}
+		if r.Vhost != "" {
+			qr.Vhost = proto.String(r.Vhost)
+		}
+		if r.MessageTTL > 0 {
+			qr.MessageTtl = proto.Int64(r.MessageTTL)
+		}
+		if r.MaxLength > 0 {
+			qr.MaxLength = proto.Int64(r.MaxLength)
+		}
+		if r.Overflow != "" {
+			qr.Overflow = proto.String(r.Overflow)
+		}
+		if r.HAMode != "" {
+			qr.HaMode = proto.String(r.HAMode)
+		}
+		if r.HAParams > 0 {
+			qr.HaParams = proto.Int64(r.HAParams)
+		}
+		if r.DeadLetter {
+			qr.DeadLetter = proto.Bool(true)
+		}
+		ret = append(ret, qr)
+	}
+	return &listqueuepolicies.Response{Rules: ret}, nil
+}
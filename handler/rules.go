@@ -29,14 +29,14 @@ func CreateBindingRuleHandler(req *server.Request) (proto.Message, errors.Error)
 		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.createrule", err.Error())
 	}
 	ruleReq := request.GetRule()
-	rule := &domain.Rule{Service: ruleReq.GetService(), Version: ruleReq.GetVersion(), Weight: ruleReq.GetWeight()}
+	rule := &domain.Rule{Service: ruleReq.GetService(), Version: ruleReq.GetVersion(), Weight: ruleReq.GetWeight(), Vhost: ruleReq.GetVhost()}
 	err = dao.CreateRule(rule)
 	if err != nil {
 		log.Errorf("Error creating rule %+v", err)
 		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.createrule", err.Error())
 	}
 
-	event.PubRuleChange(ruleReq.GetService(), ruleReq.GetVersion(), event.CreateRule, getUser(req), ruleReq.GetWeight())
+	event.PubRuleChange(ruleReq.GetService(), ruleReq.GetVersion(), ruleReq.GetVhost(), event.CreateRule, getUser(req), ruleReq.GetWeight())
 
 	// once a rule is created you need to rebind everything - just wait for the periodic refresh to pick it up
 	return &createrule.Response{Ok: proto.Bool(true)}, nil
@@ -49,14 +49,14 @@ func DeleteBindingRuleHandler(req *server.Request) (proto.Message, errors.Error)
 		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.deleterule", err.Error())
 	}
 	ruleReq := request.GetRule()
-	rule := &domain.Rule{Service: ruleReq.GetService(), Version: ruleReq.GetVersion(), Weight: ruleReq.GetWeight()}
+	rule := &domain.Rule{Service: ruleReq.GetService(), Version: ruleReq.GetVersion(), Weight: ruleReq.GetWeight(), Vhost: ruleReq.GetVhost()}
 	err = dao.DeleteRule(rule)
 	if err != nil {
 		log.Errorf("Error deleting rule %+v", err)
 		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.deleterule", err.Error())
 	}
 
-	event.PubRuleChange(ruleReq.GetService(), ruleReq.GetVersion(), event.DeleteRule, getUser(req), ruleReq.GetWeight())
+	event.PubRuleChange(ruleReq.GetService(), ruleReq.GetVersion(), ruleReq.GetVhost(), event.DeleteRule, getUser(req), ruleReq.GetWeight())
 
 	// once a rule is deleted you need to rebind everything - just wait for the periodic refresh to pick it up
 	return &deleterule.Response{Ok: proto.Bool(true)}, nil
@@ -75,7 +75,11 @@ func ListBindingRulesHandler(req *server.Request) (proto.Message, errors.Error)
 	}
 	ret := make([]*rule.BindingRule, 0)
 	for _, r := range rules {
-		ret = append(ret, &rule.BindingRule{Service: &r.Service, Version: &r.Version, Weight: &r.Weight})
+		br := &rule.BindingRule{Service: proto.String(r.Service), Version: proto.String(r.Version), Weight: proto.Int32(r.Weight)}
+		if r.Vhost != "" {
+			br.Vhost = proto.String(r.Vhost)
+		}
+		ret = append(ret, br)
 	}
 	return &listrules.Response{Rules: ret}, nil
 }
@@ -18,10 +18,8 @@ func ServiceUpListener(req *server.Request) (proto.Message, errors.Error) {
 	if err := req.Unmarshal(request); err != nil {
 		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.serviceup", err.Error())
 	}
-	errObj := binding.SetupService(domain.ServiceFromServiceupProto(request))
-	if errObj != nil {
-		return nil, errObj
-	}
+	// queued so that bursts of events for the same service are coalesced
+	binding.EnqueueServiceUp(domain.ServiceFromServiceupProto(request))
 	return &serviceup.Response{}, nil
 }
 
@@ -36,10 +34,7 @@ func ServiceDownListener(req *server.Request) (proto.Message, errors.Error) {
 	queue := request.GetInstanceId()
 	service := request.GetServiceName()
 	azname := request.GetAzName()
-	errObj := binding.TeardownService(service, queue, azname)
-	if errObj != nil {
-		return nil, errObj
-	}
+	binding.EnqueueServiceDown(service, queue, azname)
 	return &servicedown.Response{}, nil
 
 }
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/HailoOSS/binding-service/metrics"
+	stats "github.com/HailoOSS/binding-service/proto/stats"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+	"github.com/HailoOSS/protobuf/proto"
+)
+
+// Returns the metrics of the instance answering, the same ones served at /metrics
+func StatsHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &stats.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.stats", err.Error())
+	}
+	rsp := &stats.Response{}
+	for _, s := range metrics.Samples() {
+		if !strings.HasPrefix(s.Name, request.GetPrefix()) {
+			continue
+		}
+		m := &stats.Metric{Name: proto.String(s.Name), Type: proto.String(s.Type)}
+		for _, l := range s.Labels {
+			m.Labels = append(m.Labels, &stats.Label{Name: proto.String(l.Name), Value: proto.String(l.Value)})
+		}
+		if s.Type == metrics.COUNTER {
+			m.Value = proto.Float64(s.Value)
+		} else {
+			m.Count = proto.Uint64(s.Count)
+			m.Sum = proto.Float64(s.Sum)
+			for _, b := range s.Buckets {
+				m.Buckets = append(m.Buckets, &stats.Bucket{UpperBound: proto.Float64(b.UpperBound), Count: proto.Uint64(b.Count)})
+			}
+		}
+		rsp.Metrics = append(rsp.Metrics, m)
+	}
+	return rsp, nil
+}
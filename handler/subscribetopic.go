@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	log "github.com/cihub/seelog"
 	"github.com/HailoOSS/binding-service/binding"
@@ -9,7 +10,6 @@ import (
 	"github.com/HailoOSS/platform/raven"
 	"github.com/HailoOSS/platform/server"
 	"github.com/HailoOSS/protobuf/proto"
-	"net/http"
 )
 
 // Subscribe a queue to a topic
@@ -24,9 +24,18 @@ func SubscribeTopicHandler(req *server.Request) (proto.Message, errors.Error) {
 
 	log.Debug("Subscribing queue to topic ", request)
 
-	httpClient := &http.Client{}
+	vhost := request.GetVhost()
+	if vhost == "" {
+		var err error
+		vhost, err = binding.QueueVhost(context.Background(), queue)
+		if err != nil {
+			return nil, errors.BadRequest("com.HailoOSS.kernel.binding.subscribetopic", err.Error())
+		}
+	} else if !binding.IsManagedVhost(vhost) {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.subscribetopic", fmt.Sprintf("Vhost %s isn't managed by the binding service", vhost))
+	}
 
-	err := binding.CreateTopicBindingE2Q(httpClient, binding.LocalHost+":"+binding.DefaultRabbitPort, raven.TOPIC_EXCHANGE, queue, topic)
+	err := binding.CreateTopicBindingE2Q(context.Background(), binding.LocalHost, vhost, raven.TOPIC_EXCHANGE, queue, topic)
 	if err != nil {
 		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.setupservice", fmt.Sprintf("Error while creating E2Q binding h2o.topic -> %v. %v", queue, err))
 	}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/cihub/seelog"
+	"github.com/HailoOSS/binding-service/binding"
+	listsubscriptions "github.com/HailoOSS/binding-service/proto/listsubscriptions"
+	unsubscribetopic "github.com/HailoOSS/binding-service/proto/unsubscribetopic"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/raven"
+	"github.com/HailoOSS/platform/server"
+	"github.com/HailoOSS/protobuf/proto"
+)
+
+// Unsubscribe a queue from a topic by removing the binding with the topic as the routing key
+func UnsubscribeTopicHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &unsubscribetopic.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.unsubscribetopic", err.Error())
+	}
+	queue := request.GetQueue()
+	topic := request.GetTopic()
+
+	log.Debug("Unsubscribing queue from topic ", request)
+
+	vhost := request.GetVhost()
+	if vhost == "" {
+		var err error
+		vhost, err = binding.QueueVhost(context.Background(), queue)
+		if err != nil {
+			return nil, errors.BadRequest("com.HailoOSS.kernel.binding.unsubscribetopic", err.Error())
+		}
+	} else if !binding.IsManagedVhost(vhost) {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.unsubscribetopic", fmt.Sprintf("Vhost %s isn't managed by the binding service", vhost))
+	}
+
+	found, err := binding.DeleteTopicBindingE2Q(context.Background(), binding.LocalHost, vhost, raven.TOPIC_EXCHANGE, queue, topic)
+	if err != nil {
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.unsubscribetopic", fmt.Sprintf("Error while deleting E2Q binding h2o.topic -> %v. %v", queue, err))
+	}
+	if !found {
+		return nil, errors.NotFound("com.HailoOSS.kernel.binding.unsubscribetopic", fmt.Sprintf("Queue %s isn't subscribed to %s in vhost %s", queue, topic, vhost))
+	}
+
+	return &unsubscribetopic.Response{Ok: proto.Bool(true)}, nil
+}
+
+// Lists the topics a queue is subscribed to, or the queues subscribed to a topic
+func ListSubscriptionsHandler(req *server.Request) (proto.Message, errors.Error) {
+	request := &listsubscriptions.Request{}
+	if err := req.Unmarshal(request); err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.listsubscriptions", err.Error())
+	}
+	if request.GetQueue() == "" && request.GetTopic() == "" {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.listsubscriptions", "Queue or topic is required")
+	}
+	if request.GetVhost() != "" && !binding.IsManagedVhost(request.GetVhost()) {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.binding.listsubscriptions", fmt.Sprintf("Vhost %s isn't managed by the binding service", request.GetVhost()))
+	}
+
+	subs, err := binding.ListSubscriptions(context.Background(), request.GetVhost(), request.GetQueue(), request.GetTopic())
+	if err != nil {
+		log.Errorf("Error listing subscriptions %+v", err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.binding.listsubscriptions", err.Error())
+	}
+
+	rsp := &listsubscriptions.Response{}
+	for _, s := range subs {
+		rsp.Subscriptions = append(rsp.Subscriptions, &listsubscriptions.Response_Subscription{
+			Vhost: proto.String(s.Vhost),
+			Queue: proto.String(s.Queue),
+			Topic: proto.String(s.Topic),
+		})
+	}
+	return rsp, nil
+}
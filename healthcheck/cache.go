@@ -0,0 +1,70 @@
+package healthcheck
+
+import (
+	"flag"
+	"fmt"
+	"github.com/HailoOSS/service/healthcheck"
+	"sync"
+	"time"
+)
+
+var checkInterval = flag.Duration("binding_healthcheck_interval", time.Minute, "How often the binding health checks are evaluated in the background, probes are answered with the last result")
+
+// staleChecks is how many intervals without an evaluation before a cached result is treated as a failure
+const staleChecks = 3
+
+// cachedCheck evaluates a check in the background on its own schedule so that probing it doesn't touch the brokers
+type cachedCheck struct {
+	sync.RWMutex
+	check   healthcheck.Checker
+	once    sync.Once
+	details map[string]string
+	err     error
+	checked time.Time
+}
+
+func newCachedCheck(check healthcheck.Checker) *cachedCheck {
+	return &cachedCheck{check: check}
+}
+
+// Checker returns the last result, evaluation starts with the first probe
+func (c *cachedCheck) Checker() healthcheck.Checker {
+	return func() (map[string]string, error) {
+		c.once.Do(func() { go c.run() })
+		return c.result(time.Now())
+	}
+}
+
+func (c *cachedCheck) run() {
+	for {
+		c.evaluate()
+		time.Sleep(*checkInterval)
+	}
+}
+
+func (c *cachedCheck) evaluate() {
+	details, err := c.check()
+	c.Lock()
+	defer c.Unlock()
+	c.details, c.err, c.checked = details, err, time.Now()
+}
+
+// result returns the last result with when it was evaluated, or nothing if it hasn't been yet
+func (c *cachedCheck) result(now time.Time) (map[string]string, error) {
+	c.RLock()
+	defer c.RUnlock()
+	if c.checked.IsZero() {
+		return map[string]string{"status": "pending"}, nil
+	}
+	details := make(map[string]string, len(c.details)+2)
+	for k, v := range c.details {
+		details[k] = v
+	}
+	age := now.Sub(c.checked)
+	details["lastChecked"] = c.checked.Format(time.RFC3339)
+	details["age"] = age.String()
+	if age > staleChecks**checkInterval {
+		return details, fmt.Errorf("Not evaluated for %v", age)
+	}
+	return details, c.err
+}
@@ -0,0 +1,40 @@
+package healthcheck
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCachedCheck(t *testing.T) {
+	calls := 0
+	c := newCachedCheck(func() (map[string]string, error) {
+		calls++
+		return map[string]string{"foo": "bar"}, fmt.Errorf("broken")
+	})
+	if details, err := c.result(time.Now()); err != nil || details["status"] != "pending" {
+		t.Error("Should be pending before the first evaluation ", details, err)
+	}
+
+	c.evaluate()
+	details, err := c.result(time.Now())
+	if err == nil || err.Error() != "broken" || details["foo"] != "bar" || details["lastChecked"] == "" {
+		t.Error("Should return the last result ", details, err)
+	}
+	c.result(time.Now())
+	if calls != 1 {
+		t.Error("Results should be answered from the cache ", calls)
+	}
+	if details["age"] == "" || c.details["age"] != "" {
+		t.Error("The age shouldn't be added to the cached details ", c.details)
+	}
+
+	c.check = func() (map[string]string, error) { return nil, nil }
+	c.evaluate()
+	if _, err := c.result(time.Now()); err != nil {
+		t.Error("Should be healthy ", err)
+	}
+	if _, err := c.result(time.Now().Add(staleChecks**checkInterval + time.Second)); err == nil {
+		t.Error("Should fail once the result is stale")
+	}
+}
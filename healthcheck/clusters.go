@@ -0,0 +1,83 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"github.com/HailoOSS/binding-service/binding"
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/rabbit"
+	"github.com/HailoOSS/binding-service/util"
+	"sort"
+	"strings"
+)
+
+// clusterBindings is what a cluster has for the binding checks to compare discovery against. They're read from one
+// of its nodes as they're the same on all of them.
+type clusterBindings struct {
+	bindings []*domain.BindingDef
+	queues   []*rabbit.Queue // only if they were asked for
+}
+
+// compareFunc returns the inconsistencies between the instances in discovery and the clusters, which are keyed by az
+type compareFunc func(services []*domain.Service, clusters map[string]*clusterBindings) map[string]string
+
+// checkClusters compares the instances in discovery against the bindings, and the queues if withQueues, on the
+// clusters of azs or every cluster if none are given. The inconsistencies are returned with an error listing them as
+// inconsistent what.
+func checkClusters(what string, azs []string, withQueues bool, compare compareFunc) (map[string]string, error) {
+	rabMap, err := util.GetRabbitHosts()
+	if err != nil {
+		return nil, err
+	}
+	if len(azs) == 0 {
+		for az := range rabMap {
+			azs = append(azs, az)
+		}
+	}
+
+	services, err := binding.GetInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	client := rabbit.DefaultClient()
+	clusters := make(map[string]*clusterBindings, len(azs))
+	for _, az := range azs {
+		hosts, ok := rabMap[az]
+		if !ok {
+			return nil, fmt.Errorf("No rabbit hosts for %s", az)
+		}
+		host := client.PickNode(hosts)
+		c := &clusterBindings{}
+		if c.bindings, err = client.GetBindings(context.Background(), host); err != nil {
+			return nil, err
+		}
+		if withQueues {
+			if c.queues, err = client.GetQueues(context.Background(), host); err != nil {
+				return nil, err
+			}
+		}
+		clusters[az] = c
+	}
+
+	return inconsistencies(what, compare(services, clusters))
+}
+
+// inconsistencies returns the error for a check which found errorMap, listing all of its keys
+func inconsistencies(what string, errorMap map[string]string) (map[string]string, error) {
+	return problems("Inconsistent "+what, errorMap)
+}
+
+// problems returns errorMap with an error counting and listing every one of its keys after desc, or nothing if it's
+// empty
+func problems(desc string, errorMap map[string]string) (map[string]string, error) {
+	if len(errorMap) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(errorMap))
+	for k := range errorMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return errorMap, fmt.Errorf("%d %s: %s", len(keys), desc, strings.Join(keys, ", "))
+}
@@ -0,0 +1,33 @@
+package healthcheck
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInconsistencies(t *testing.T) {
+	if res, err := inconsistencies("topic bindings", map[string]string{}); res != nil || err != nil {
+		t.Error("Nothing inconsistent should pass ", res, err)
+	}
+
+	errorMap := make(map[string]string)
+	for i := 0; i < 30; i++ {
+		errorMap["eu-west-1a-some-long-queue-name-"+strings.Repeat("x", i)+"-some.topic"] = "missing"
+	}
+	res, err := inconsistencies("topic bindings", errorMap)
+	if err == nil || len(res) != 30 {
+		t.Fatal("Should fail with every inconsistency ", res, err)
+	}
+	if !strings.HasPrefix(err.Error(), "30 Inconsistent topic bindings: eu-west-1a-some-long-queue-name--some.topic, ") {
+		t.Error("Wrong error ", err)
+	}
+	for k := range errorMap {
+		if !strings.Contains(err.Error(), k) {
+			t.Error("Error should list every key, not ", k)
+		}
+	}
+
+	if _, err := problems("Federation links down", map[string]string{"b": "down", "a": "down"}); err == nil || err.Error() != "2 Federation links down: a, b" {
+		t.Error("Problems should be counted and listed in order ", err)
+	}
+}
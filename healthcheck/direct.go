@@ -0,0 +1,74 @@
+package healthcheck
+
+import (
+	"github.com/HailoOSS/binding-service/binding"
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/rabbit"
+	"github.com/HailoOSS/service/healthcheck"
+	"strings"
+)
+
+const DirectHealthCheckId = "com.HailoOSS.service.directbindings"
+
+// DirectHealthCheck asserts every instance is bound to h2o.direct by its ID and service
+func DirectHealthCheck() healthcheck.Checker {
+	return newCachedCheck(checkDirectBindings).Checker()
+}
+
+// checks each instance has its h2o.direct bindings, and that there are no h2o.direct bindings to queues which don't
+// exist or to the direct exchanges of other AZs for instances and services which aren't running there. Missing
+// bindings to other AZs aren't reported as they're left out while federation is down.
+func checkDirectBindings() (map[string]string, error) {
+	return checkClusters("direct bindings", nil, true, func(services []*domain.Service, clusters map[string]*clusterBindings) map[string]string {
+		errorMap := make(map[string]string)
+		for azname, c := range clusters {
+			for k, v := range directInconsistencies(azname, services, c.bindings, c.queues) {
+				errorMap[k] = v
+			}
+		}
+		return errorMap
+	})
+}
+
+// directInconsistencies compares the instances in every az against the h2o.direct bindings and queues on the
+// cluster of azname. The result is keyed by "<az>-<destination>-<routing key>" with the problem, missing or stale.
+func directInconsistencies(azname string, services []*domain.Service, bindings []*domain.BindingDef, queues []*rabbit.Queue) map[string]string {
+	exists := make(map[string]bool, len(queues))
+	for _, q := range queues {
+		exists[q.Vhost+"|"+q.Name] = true
+	}
+	running := make(map[string]bool)
+	for _, s := range services {
+		running[binding.DirectAzExchange(s.AzName)+"|"+s.Instance] = true
+		running[binding.DirectAzExchange(s.AzName)+"|"+s.Service] = true
+	}
+	bound := make(map[string]bool)
+	res := make(map[string]string)
+	for _, b := range bindings {
+		if b.Source != binding.DIRECT_EXCHANGE || !binding.IsManagedVhost(b.Vhost) {
+			continue
+		}
+		bound[b.Destination+"|"+b.RoutingKey] = true
+		switch b.DestinationType {
+		case string(domain.QUEUE):
+			if !exists[b.Vhost+"|"+b.Destination] {
+				res[azname+"-"+b.Destination+"-"+b.RoutingKey] = "stale"
+			}
+		case string(domain.EXCHANGE):
+			if strings.HasPrefix(b.Destination, binding.DIRECT_EXCHANGE+".") && !running[b.Destination+"|"+b.RoutingKey] {
+				res[azname+"-"+b.Destination+"-"+b.RoutingKey] = "stale"
+			}
+		}
+	}
+	for _, s := range services {
+		if s.AzName != azname {
+			continue
+		}
+		for _, key := range []string{s.Instance, s.Service} {
+			if !bound[s.Instance+"|"+key] {
+				res[azname+"-"+s.Instance+"-"+key] = "missing"
+			}
+		}
+	}
+	return res
+}
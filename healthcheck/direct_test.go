@@ -0,0 +1,41 @@
+package healthcheck
+
+import (
+	"testing"
+
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/rabbit"
+)
+
+func TestDirectInconsistencies(t *testing.T) {
+	services := []*domain.Service{
+		{Service: "com.HailoOSS.foo", Instance: "foo-1", AzName: "eu-west-1a"},
+		{Service: "com.HailoOSS.bar", Instance: "bar-1", AzName: "eu-west-1b"},
+	}
+	direct := func(dest, destType, key string) *domain.BindingDef {
+		return &domain.BindingDef{Source: "h2o.direct", Vhost: "/", Destination: dest, DestinationType: destType, RoutingKey: key}
+	}
+	bindings := []*domain.BindingDef{
+		direct("foo-1", "queue", "foo-1"),
+		direct("gone-1", "queue", "gone-1"),
+		direct("h2o.direct.eu-west-1b", "exchange", "bar-1"),
+		direct("h2o.direct.eu-west-1b", "exchange", "com.HailoOSS.bar"),
+		direct("h2o.direct.eu-west-1b", "exchange", "bar-2"),
+		{Source: "h2o", Vhost: "/", Destination: "foo-1", DestinationType: "queue", RoutingKey: "com.HailoOSS.foo"},
+	}
+	queues := []*rabbit.Queue{{Name: "foo-1", Vhost: "/"}}
+
+	res := directInconsistencies("eu-west-1a", services, bindings, queues)
+	if len(res) != 3 {
+		t.Fatal("Should be three inconsistencies ", res)
+	}
+	if res["eu-west-1a-foo-1-com.HailoOSS.foo"] != "missing" {
+		t.Error("Binding by service should be missing ", res)
+	}
+	if res["eu-west-1a-gone-1-gone-1"] != "stale" {
+		t.Error("Binding to a queue which doesn't exist should be stale ", res)
+	}
+	if res["eu-west-1a-h2o.direct.eu-west-1b-bar-2"] != "stale" {
+		t.Error("Binding to another AZ for an instance which isn't running should be stale ", res)
+	}
+}
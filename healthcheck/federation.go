@@ -0,0 +1,121 @@
+package healthcheck
+
+import (
+	"github.com/HailoOSS/binding-service/binding"
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/util"
+	"github.com/HailoOSS/platform/raven"
+	"github.com/HailoOSS/service/healthcheck"
+	log "github.com/cihub/seelog"
+	"sort"
+	"strings"
+)
+
+const FederationHealthCheckId = "com.HailoOSS.service.federationbindings"
+
+// FederationHealthChecks returns a check for each other AZ in the rabbit hosts, keyed by check ID, asserting this AZ's
+// and that AZ's clusters are bound to each other for the services running in them. AZs added to the rabbit hosts
+// later aren't checked until the service restarts.
+func FederationHealthChecks() map[string]healthcheck.Checker {
+	res := make(map[string]healthcheck.Checker)
+	rabMap, err := util.GetRabbitHosts()
+	if err != nil {
+		log.Errorf("Error while retrieving the rabbit hosts for the federation health checks %v", err)
+		return res
+	}
+	for az := range rabMap {
+		if az == binding.ThisAz() {
+			continue
+		}
+		remote := az
+		res[FederationHealthCheckId+"."+remote] = newCachedCheck(func() (map[string]string, error) {
+			return checkFederationBindings(binding.ThisAz(), remote)
+		}).Checker()
+	}
+	return res
+}
+
+// checks that the clusters of the pair of azs each have an h2o -> <az> binding for every service running in the
+// other, and no bindings to the other az for services which aren't running there. Inconsistencies are reported per
+// direction. The pair isn't checked while federation between them is down in either direction, as the bindings to
+// the az it's down from are torn down then.
+func checkFederationBindings(thisAz, remote string) (map[string]string, error) {
+	if binding.IsFederationDownTo(thisAz, remote) {
+		return map[string]string{"status": "federation from " + remote + " is down, not checked"}, nil
+	}
+	if binding.IsFederationDownTo(remote, thisAz) {
+		return map[string]string{"status": "federation from " + thisAz + " is down, not checked"}, nil
+	}
+	return checkClusters("federation bindings", []string{thisAz, remote}, false, func(services []*domain.Service, clusters map[string]*clusterBindings) map[string]string {
+		running := map[string]map[string]bool{thisAz: {}, remote: {}}
+		for _, s := range services {
+			if _, ok := running[s.AzName]; ok && !binding.IsLocalService(s.Service) {
+				running[s.AzName][s.Service] = true
+			}
+		}
+		bindings := make(map[string][]*domain.BindingDef, len(clusters))
+		for az, c := range clusters {
+			bindings[az] = c.bindings
+		}
+		return federationInconsistencies(running, bindings)
+	})
+}
+
+// federationInconsistencies compares the services running in each az against the bindings to it on each other
+// cluster. The result is keyed by "<cluster az>-><az>" and lists the missing and stale services.
+func federationInconsistencies(running map[string]map[string]bool, bindings map[string][]*domain.BindingDef) map[string]string {
+	res := make(map[string]string)
+	for az, bs := range bindings {
+		// services bound from this cluster to each other az
+		bound := make(map[string]map[string]bool)
+		for _, b := range bs {
+			if b.Source != raven.EXCHANGE || b.DestinationType != string(domain.EXCHANGE) || !binding.IsManagedVhost(b.Vhost) {
+				continue
+			}
+			if _, ok := running[b.Destination]; !ok || b.Destination == az {
+				// not an az exchange
+				continue
+			}
+			service, _ := b.Arguments["service"].(string)
+			if service != b.RoutingKey {
+				// without the right service argument the binding doesn't route anything
+				continue
+			}
+			if bound[b.Destination] == nil {
+				bound[b.Destination] = make(map[string]bool)
+			}
+			bound[b.Destination][service] = true
+		}
+
+		for remote, services := range running {
+			if remote == az {
+				continue
+			}
+			missing, stale := make([]string, 0), make([]string, 0)
+			for s := range services {
+				if !bound[remote][s] {
+					missing = append(missing, s)
+				}
+			}
+			for s := range bound[remote] {
+				if !services[s] {
+					stale = append(stale, s)
+				}
+			}
+			if len(missing) == 0 && len(stale) == 0 {
+				continue
+			}
+			sort.Strings(missing)
+			sort.Strings(stale)
+			problems := make([]string, 0, 2)
+			if len(missing) > 0 {
+				problems = append(problems, "missing "+strings.Join(missing, " "))
+			}
+			if len(stale) > 0 {
+				problems = append(problems, "stale "+strings.Join(stale, " "))
+			}
+			res[az+"->"+remote] = strings.Join(problems, "; ")
+		}
+	}
+	return res
+}
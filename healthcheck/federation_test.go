@@ -0,0 +1,40 @@
+package healthcheck
+
+import (
+	"testing"
+
+	"github.com/HailoOSS/binding-service/domain"
+)
+
+func TestFederationInconsistencies(t *testing.T) {
+	running := map[string]map[string]bool{
+		"eu-west-1a": {"com.HailoOSS.foo": true, "com.HailoOSS.bar": true},
+		"eu-west-1b": {"com.HailoOSS.foo": true},
+	}
+	foo := &domain.Service{Service: "com.HailoOSS.foo", Vhost: "/"}
+	bar := &domain.Service{Service: "com.HailoOSS.bar", Vhost: "/"}
+	wrongService := domain.ExchangeBindingDefFromService(bar, "eu-west-1a")
+	wrongService.Arguments["service"] = "com.HailoOSS.baz"
+	bindings := map[string][]*domain.BindingDef{
+		// bar isn't bound properly
+		"eu-west-1b": {domain.ExchangeBindingDefFromService(foo, "eu-west-1a"), wrongService},
+		// bar isn't running in 1b
+		"eu-west-1a": {domain.ExchangeBindingDefFromService(foo, "eu-west-1b"), domain.ExchangeBindingDefFromService(bar, "eu-west-1b")},
+	}
+	res := federationInconsistencies(running, bindings)
+	if len(res) != 2 {
+		t.Fatal("Should be inconsistencies for both AZ pairs ", res)
+	}
+	if res["eu-west-1b->eu-west-1a"] != "missing com.HailoOSS.bar" {
+		t.Error("Binding with the wrong service argument should be missing ", res)
+	}
+	if res["eu-west-1a->eu-west-1b"] != "stale com.HailoOSS.bar" {
+		t.Error("Binding for a service with no instances should be stale ", res)
+	}
+
+	bindings["eu-west-1b"][1] = domain.ExchangeBindingDefFromService(bar, "eu-west-1a")
+	bindings["eu-west-1a"] = bindings["eu-west-1a"][:1]
+	if res := federationInconsistencies(running, bindings); len(res) != 0 {
+		t.Error("Should be consistent ", res)
+	}
+}
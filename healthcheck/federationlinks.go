@@ -0,0 +1,53 @@
+package healthcheck
+
+import (
+	"fmt"
+	"github.com/HailoOSS/binding-service/binding"
+	"github.com/HailoOSS/service/healthcheck"
+	"time"
+)
+
+const FederationLinksHealthCheckId = "com.HailoOSS.service.federationlinks"
+
+// FederationLinksHealthCheck asserts the federation links on every cluster are running. The links are polled in the
+// background so this only reads their last known state.
+func FederationLinksHealthCheck() healthcheck.Checker {
+	return checkFederationLinks
+}
+
+func checkFederationLinks() (map[string]string, error) {
+	return federationLinkProblems(binding.GetFederationStatus(""), time.Now())
+}
+
+// federationLinkProblems reports the links which aren't running, keyed by "<az>-<vhost>-<upstream>", and the clusters
+// whose links couldn't be retrieved
+func federationLinkProblems(status *binding.FederationStatus, now time.Time) (map[string]string, error) {
+	if status.Checked.IsZero() {
+		return map[string]string{"status": "pending"}, nil
+	}
+	down := make(map[string]string)
+	for _, l := range status.Links {
+		if l.Status == binding.LINK_RUNNING {
+			continue
+		}
+		key := l.AzName + "-" + l.Vhost + "-" + l.Upstream
+		down[key] = fmt.Sprintf("%s since %s", l.Status, l.Since.Format(time.RFC3339))
+		if l.Error != "" {
+			down[key] += ": " + l.Error
+		}
+	}
+	for az, err := range status.Errs {
+		down[az] = err.Error()
+	}
+
+	if age := now.Sub(status.Checked); age > staleChecks*binding.FederationPoll() {
+		down["lastChecked"] = status.Checked.Format(time.RFC3339)
+		return down, fmt.Errorf("Federation links not checked for %v", age)
+	}
+	errorMap, err := problems("Federation links down or clusters unreachable", down)
+	if errorMap == nil {
+		errorMap = make(map[string]string)
+	}
+	errorMap["lastChecked"] = status.Checked.Format(time.RFC3339)
+	return errorMap, err
+}
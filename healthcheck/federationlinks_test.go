@@ -0,0 +1,37 @@
+package healthcheck
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HailoOSS/binding-service/binding"
+)
+
+func TestFederationLinkProblems(t *testing.T) {
+	now := time.Now()
+	status := &binding.FederationStatus{Checked: now, Errs: map[string]error{"eu-west-1c": fmt.Errorf("unreachable")}}
+	for i := 0; i < 20; i++ {
+		status.Links = append(status.Links, &binding.FederationLink{AzName: "eu-west-1a", Vhost: "/", Upstream: fmt.Sprintf("rabbit-with-a-long-name-%d", i), Status: "error", Since: now})
+	}
+	status.Links = append(status.Links, &binding.FederationLink{AzName: "eu-west-1a", Vhost: "/", Upstream: "rabbit-up", Status: binding.LINK_RUNNING, Since: now})
+
+	res, err := federationLinkProblems(status, now)
+	if err == nil || !strings.HasPrefix(err.Error(), "21 Federation links down or clusters unreachable: ") {
+		t.Fatal("Every down link and unreachable cluster should be counted ", err)
+	}
+	for k := range res {
+		if k != "lastChecked" && !strings.Contains(err.Error(), k) {
+			t.Error("Error should list ", k)
+		}
+	}
+	if _, ok := res["eu-west-1a-/-rabbit-up"]; ok || res["lastChecked"] == "" {
+		t.Error("Running links shouldn't be reported ", res)
+	}
+
+	status.Links, status.Errs = status.Links[20:], nil
+	if res, err := federationLinkProblems(status, now); err != nil || len(res) != 1 {
+		t.Error("Should pass with every link running ", res, err)
+	}
+}
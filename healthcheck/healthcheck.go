@@ -1,103 +1,52 @@
 package healthcheck
 
 import (
-	"fmt"
-	"github.com/HailoOSS/binding-service/util"
-	instances "github.com/HailoOSS/discovery-service/proto/instances"
-	"github.com/HailoOSS/platform/client"
-	"github.com/HailoOSS/platform/server"
+	"github.com/HailoOSS/binding-service/binding"
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/platform/raven"
 	"github.com/HailoOSS/service/healthcheck"
-	"github.com/HailoOSS/binding-service/rabbit"
-	"github.com/HailoOSS/protobuf/proto"
-	"sort"
-	"strings"
 )
 
 const HealthCheckId = "com.HailoOSS.service.bindings"
 
-// HealthCheck asserts all the local queues are bound that should be bound
+// HealthCheck asserts all the local queues are bound that should be bound. It's evaluated in the background and
+// answered from the last result.
 func BindingHealthCheck() healthcheck.Checker {
-	return checkBindings
+	return newCachedCheck(checkBindings).Checker()
 }
 
 //checks what is bound against what is returned in the discovery service
-//only checks local bindings, not federated bindings
+//only checks local bindings, federated bindings are checked by checkFederationBindings
 func checkBindings() (map[string]string, error) {
-
-	errorMap := make(map[string]string)
-	errorArr := sort.StringSlice{}
-
-	//get rabbit host from disk (everytime, might be bad if called lots)
-	rabMap, err := util.GetRabbitHosts()
-	if err != nil {
-		return nil, err
-	}
-
-	//Loop through az's
-	for azname, hosts := range rabMap {
-
-		ir := &instances.Request{
-			AzName: proto.String(azname),
-		}
-
-		instancesReq, err := server.ScopedRequest("com.HailoOSS.kernel.discovery", "instances", ir)
-		if err != nil {
-			return nil, err
-		}
-
-		instancesRsp := &instances.Response{}
-
-		// send the instances request
-		if err := client.Req(instancesReq, instancesRsp); err != nil {
-			return nil, err
+	return checkClusters("bindings", nil, false, func(services []*domain.Service, clusters map[string]*clusterBindings) map[string]string {
+		byAz := make(map[string][]*domain.Service)
+		for _, s := range services {
+			byAz[s.AzName] = append(byAz[s.AzName], s)
 		}
-
-		//loop through rabbit hosts
-		for _, rabbitHost := range hosts {
-
-			rabbitBindings, err := rabbit.GetBindings("http://" + rabbitHost)
-			if err != nil {
-				return nil, err
+		errorMap := make(map[string]string)
+		for azname, c := range clusters {
+			for k, v := range missingBindings(azname, byAz[azname], c.bindings) {
+				errorMap[k] = v
 			}
+		}
+		return errorMap
+	})
+}
 
-			//loop through discovery service instances
-			for _, instance := range instancesRsp.GetInstances() {
-				instId := instance.GetInstanceId()
-				servName := instance.GetServiceName()
-
-				//flag if instance is found and bound
-				found := false
-
-				//loop through rabbit bindings
-				for _, rabbitBinding := range rabbitBindings {
-					//check local bindings
-					if rabbitBinding.Source == "h2o" {
-						//check the routing key
-						if rabbitBinding.Routing_key == servName {
-							//check the destination
-							if rabbitBinding.Destination == instId {
-								found = true
-							}
-						}
-					}
-				}
-				//Put the missing bindings into the error map
-				if !found {
-					errorMap[azname+"-rabbit."+rabbitHost+"-"+servName] = instId
-					errorArr = append(errorArr, azname+"-rabbit."+rabbitHost+"-"+servName)
-				}
-			}
+// missingBindings returns the instances with no h2o binding on the az's cluster, keyed by "<az>-<service>-<instance>"
+func missingBindings(azname string, services []*domain.Service, rabbitBindings []*domain.BindingDef) map[string]string {
+	//index the local bindings in the vhosts we manage by routing key and destination
+	bound := make(map[string]bool, len(rabbitBindings))
+	for _, b := range rabbitBindings {
+		if b.Source == raven.EXCHANGE && binding.IsManagedVhost(b.Vhost) {
+			bound[b.RoutingKey+"|"+b.Destination] = true
 		}
 	}
-
-	if len(errorMap) != 0 {
-		sort.Sort(errorArr)
-		bindings := strings.Join(errorArr, ", ")
-		if len(bindings) > 255 {
-			bindings = bindings[:255]
+	res := make(map[string]string)
+	for _, s := range services {
+		if !bound[s.Service+"|"+s.Instance] {
+			res[azname+"-"+s.Service+"-"+s.Instance] = "missing"
 		}
-		return errorMap, fmt.Errorf("%d Inconsistant bindings: %s", len(errorArr), bindings)
 	}
-
-	return nil, nil
+	return res
 }
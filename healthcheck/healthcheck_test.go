@@ -0,0 +1,18 @@
+package healthcheck
+
+import (
+	"testing"
+
+	"github.com/HailoOSS/binding-service/domain"
+)
+
+func TestMissingBindings(t *testing.T) {
+	foo := &domain.Service{Service: "com.HailoOSS.foo", Instance: "foo-1", Vhost: "/"}
+	bar := &domain.Service{Service: "com.HailoOSS.bar", Instance: "bar-1", Vhost: "/"}
+	other := domain.BindingDefFromService(bar)
+	other.Vhost = "other"
+	res := missingBindings("eu-west-1a", []*domain.Service{foo, bar}, []*domain.BindingDef{domain.BindingDefFromService(foo), other})
+	if len(res) != 1 || res["eu-west-1a-com.HailoOSS.bar-bar-1"] != "missing" {
+		t.Error("Only the binding in an unmanaged vhost should be missing ", res)
+	}
+}
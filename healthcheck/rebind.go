@@ -0,0 +1,34 @@
+package healthcheck
+
+import (
+	"fmt"
+	"github.com/HailoOSS/binding-service/binding"
+	"github.com/HailoOSS/service/healthcheck"
+	"strconv"
+	"time"
+)
+
+const RebindHealthCheckId = "com.HailoOSS.service.rebind"
+
+// RebindHealthCheck asserts the last rebind cycle completed within its timeout
+func RebindHealthCheck() healthcheck.Checker {
+	return checkRebind
+}
+
+func checkRebind() (map[string]string, error) {
+	st := binding.GetRebindStatus()
+	if st.LastStart.IsZero() {
+		return nil, nil
+	}
+	details := map[string]string{
+		"lastStart":    st.LastStart.Format(time.RFC3339),
+		"lastDuration": st.LastDuration.String(),
+		"completed":    strconv.Itoa(st.Completed),
+		"instances":    strconv.Itoa(st.Instances),
+		"overruns":     strconv.Itoa(st.Overruns),
+	}
+	if st.Err != nil {
+		return details, fmt.Errorf("Rebinding overran %d consecutive times: %v", st.Overruns, st.Err)
+	}
+	return details, nil
+}
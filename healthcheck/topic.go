@@ -0,0 +1,62 @@
+package healthcheck
+
+import (
+	"github.com/HailoOSS/binding-service/binding"
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/rabbit"
+	"github.com/HailoOSS/platform/raven"
+	"github.com/HailoOSS/service/healthcheck"
+)
+
+const TopicHealthCheckId = "com.HailoOSS.service.topicbindings"
+
+// TopicHealthCheck asserts every topic subscription advertised in discovery is bound
+func TopicHealthCheck() healthcheck.Checker {
+	return newCachedCheck(checkTopicBindings).Checker()
+}
+
+// checks each instance has an h2o.topic binding for every topic it subscribes to, and that there are no h2o.topic
+// bindings to queues which don't exist
+func checkTopicBindings() (map[string]string, error) {
+	return checkClusters("topic bindings", nil, true, func(services []*domain.Service, clusters map[string]*clusterBindings) map[string]string {
+		byAz := make(map[string][]*domain.Service)
+		for _, s := range services {
+			byAz[s.AzName] = append(byAz[s.AzName], s)
+		}
+		errorMap := make(map[string]string)
+		for azname, c := range clusters {
+			for k, v := range topicInconsistencies(azname, byAz[azname], c.bindings, c.queues) {
+				errorMap[k] = v
+			}
+		}
+		return errorMap
+	})
+}
+
+// topicInconsistencies compares the subscriptions of the instances in an az against the h2o.topic bindings and
+// queues on its cluster. The result is keyed by "<az>-<queue>-<topic>" with the problem, missing or stale.
+func topicInconsistencies(azname string, services []*domain.Service, bindings []*domain.BindingDef, queues []*rabbit.Queue) map[string]string {
+	exists := make(map[string]bool, len(queues))
+	for _, q := range queues {
+		exists[q.Vhost+"|"+q.Name] = true
+	}
+	bound := make(map[string]bool)
+	res := make(map[string]string)
+	for _, b := range bindings {
+		if b.Source != raven.TOPIC_EXCHANGE || b.DestinationType != string(domain.QUEUE) || !binding.IsManagedVhost(b.Vhost) {
+			continue
+		}
+		bound[b.Destination+"|"+b.RoutingKey] = true
+		if !exists[b.Vhost+"|"+b.Destination] {
+			res[azname+"-"+b.Destination+"-"+b.RoutingKey] = "stale"
+		}
+	}
+	for _, s := range services {
+		for _, sub := range s.Subscriptions {
+			if sub != "" && !bound[s.Instance+"|"+sub] {
+				res[azname+"-"+s.Instance+"-"+sub] = "missing"
+			}
+		}
+	}
+	return res
+}
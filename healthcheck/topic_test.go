@@ -0,0 +1,36 @@
+package healthcheck
+
+import (
+	"testing"
+
+	"github.com/HailoOSS/binding-service/domain"
+	"github.com/HailoOSS/binding-service/rabbit"
+	"github.com/HailoOSS/platform/raven"
+)
+
+func TestTopicInconsistencies(t *testing.T) {
+	services := []*domain.Service{
+		{Service: "com.HailoOSS.foo", Instance: "foo-1", Subscriptions: []string{"some.topic", "other.topic", ""}},
+	}
+	topic := func(queue, key string) *domain.BindingDef {
+		return &domain.BindingDef{Source: raven.TOPIC_EXCHANGE, Vhost: "/", Destination: queue, DestinationType: "queue", RoutingKey: key}
+	}
+	bindings := []*domain.BindingDef{
+		topic("foo-1", "some.topic"),
+		topic("foo-1", "wrong.topic"),
+		topic("gone-1", "some.topic"),
+		{Source: raven.EXCHANGE, Vhost: "/", Destination: "gone-2", DestinationType: "queue", RoutingKey: "com.HailoOSS.gone"},
+	}
+	queues := []*rabbit.Queue{{Name: "foo-1", Vhost: "/"}}
+
+	res := topicInconsistencies("eu-west-1a", services, bindings, queues)
+	if len(res) != 2 {
+		t.Fatal("Should be two inconsistencies ", res)
+	}
+	if res["eu-west-1a-foo-1-other.topic"] != "missing" {
+		t.Error("Subscription with the wrong routing key should be missing ", res)
+	}
+	if res["eu-west-1a-gone-1-some.topic"] != "stale" {
+		t.Error("Binding to a queue which doesn't exist should be stale ", res)
+	}
+}
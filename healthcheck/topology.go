@@ -0,0 +1,50 @@
+package healthcheck
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/HailoOSS/binding-service/binding"
+	"github.com/HailoOSS/service/healthcheck"
+)
+
+const TopologyHealthCheckId = "com.HailoOSS.service.topology"
+
+var topologyRepair = flag.Bool("binding_topology_repair", false, "Create the missing exchanges, queues, bindings, federation upstreams and policies found by the topology health check")
+
+// TopologyHealthCheck asserts every cluster has the exchanges, queues, bindings, federation upstreams and policies it needs
+func TopologyHealthCheck() healthcheck.Checker {
+	return newCachedCheck(checkTopology).Checker()
+}
+
+// checks the topology of every cluster, problems which were repaired are reported but don't fail the check
+func checkTopology() (map[string]string, error) {
+	problemList, err := binding.CheckTopology(context.Background(), *topologyRepair)
+	if err != nil {
+		return nil, err
+	}
+	failing := make(map[string]string)
+	repaired := make(map[string]string)
+	for _, p := range problemList {
+		switch {
+		case p.Repaired:
+			repaired[p.Key()] = p.Problem + ", repaired"
+		case p.RepairErr != nil:
+			failing[p.Key()] = fmt.Sprintf("%s, repair failed: %v", p.Problem, p.RepairErr)
+		default:
+			failing[p.Key()] = p.Problem
+		}
+	}
+
+	errorMap, err := problems("Missing or misconfigured broker objects", failing)
+	if len(repaired) == 0 {
+		return errorMap, err
+	}
+	if errorMap == nil {
+		errorMap = make(map[string]string, len(repaired))
+	}
+	for k, v := range repaired {
+		errorMap[k] = v
+	}
+	return errorMap, err
+}
@@ -0,0 +1,35 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"github.com/HailoOSS/binding-service/binding"
+	"github.com/HailoOSS/service/healthcheck"
+)
+
+const UnroutableHealthCheckId = "com.HailoOSS.service.unroutable"
+
+// UnroutableHealthCheck asserts no service has been sent messages h2o couldn't route recently, on any cluster
+func UnroutableHealthCheck() healthcheck.Checker {
+	return newCachedCheck(checkUnroutable).Checker()
+}
+
+func checkUnroutable() (map[string]string, error) {
+	reports, err := binding.CheckUnroutable(context.Background(), "")
+	if err != nil {
+		return nil, err
+	}
+	return unroutableServices(reports)
+}
+
+// unroutableServices reports the services with unroutable messages, keyed by "<az>-<vhost>-<service>" with how many of
+// the sampled messages were for them
+func unroutableServices(reports []*binding.UnroutableReport) (map[string]string, error) {
+	errorMap := make(map[string]string)
+	for _, r := range reports {
+		for service, n := range r.Services {
+			errorMap[r.AzName+"-"+r.Vhost+"-"+service] = fmt.Sprintf("%d of %d sampled from %d unroutable messages", n, r.Sampled, r.Messages)
+		}
+	}
+	return problems("Services receiving unroutable messages", errorMap)
+}
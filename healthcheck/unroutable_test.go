@@ -0,0 +1,28 @@
+package healthcheck
+
+import (
+	"testing"
+
+	"github.com/HailoOSS/binding-service/binding"
+)
+
+func TestUnroutableServices(t *testing.T) {
+	reports := []*binding.UnroutableReport{
+		{AzName: "eu-west-1a", Vhost: "/", Messages: 40, Sampled: 10, Services: map[string]int{"com.HailoOSS.foo": 7, "com.HailoOSS.bar": 3}},
+		{AzName: "eu-west-1b", Vhost: "/", Services: map[string]int{}},
+	}
+	res, err := unroutableServices(reports)
+	if err == nil {
+		t.Fatal("Should fail with services receiving unroutable messages")
+	}
+	if err.Error() != "2 Services receiving unroutable messages: eu-west-1a-/-com.HailoOSS.bar, eu-west-1a-/-com.HailoOSS.foo" {
+		t.Error("Wrong error ", err)
+	}
+	if res["eu-west-1a-/-com.HailoOSS.foo"] != "7 of 10 sampled from 40 unroutable messages" {
+		t.Error("Wrong details ", res)
+	}
+
+	if res, err := unroutableServices(reports[1:]); err != nil || res != nil {
+		t.Error("Should pass without unroutable messages ", res, err)
+	}
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"flag"
 	log "github.com/cihub/seelog"
 	"github.com/HailoOSS/binding-service/binding"
 	"github.com/HailoOSS/binding-service/handler"
 	bindinghealth "github.com/HailoOSS/binding-service/healthcheck"
+	"github.com/HailoOSS/binding-service/metrics"
 	"github.com/HailoOSS/platform/server"
 	"github.com/HailoOSS/service/zookeeper"
+	"net/http"
 	"time"
 )
 
+var metricsAddr = flag.String("binding_metrics_addr", ":9102", "Address to serve Prometheus metrics on at /metrics, empty to disable")
+
 func main() {
 	defer log.Flush()
 
@@ -47,6 +52,114 @@ func main() {
 		Authoriser: server.OpenToTheWorldAuthoriser(),
 	})
 
+	server.Register(&server.Endpoint{
+		Name:       "leader",
+		Handler:    handler.LeaderHandler,
+		Authoriser: server.OpenToTheWorldAuthoriser(),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "nodehealth",
+		Handler:    handler.NodeHealthHandler,
+		Authoriser: server.OpenToTheWorldAuthoriser(),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "stats",
+		Handler:    handler.StatsHandler,
+		Authoriser: server.OpenToTheWorldAuthoriser(),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "drift",
+		Handler:    handler.DriftHandler,
+		Authoriser: server.OpenToTheWorldAuthoriser(),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "federationlinks",
+		Handler:    handler.FederationLinksHandler,
+		Authoriser: server.OpenToTheWorldAuthoriser(),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "orphanedqueues",
+		Handler:    handler.OrphanedQueuesHandler,
+		Authoriser: server.OpenToTheWorldAuthoriser(),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "inspectqueue",
+		Handler:    handler.InspectQueueHandler,
+		Authoriser: server.RoleAuthoriser([]string{"ADMIN"}),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "purgequeue",
+		Handler:    handler.PurgeQueueHandler,
+		Authoriser: server.RoleAuthoriser([]string{"ADMIN"}),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "createdeadletterrule",
+		Handler:    handler.CreateDeadLetterRuleHandler,
+		Authoriser: server.OpenToTheWorldAuthoriser(),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "deletedeadletterrule",
+		Handler:    handler.DeleteDeadLetterRuleHandler,
+		Authoriser: server.OpenToTheWorldAuthoriser(),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "listdeadletterrules",
+		Handler:    handler.ListDeadLetterRulesHandler,
+		Authoriser: server.OpenToTheWorldAuthoriser(),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "deadletters",
+		Handler:    handler.DeadLettersHandler,
+		Authoriser: server.RoleAuthoriser([]string{"ADMIN"}),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "replaydeadletters",
+		Handler:    handler.ReplayDeadLettersHandler,
+		Authoriser: server.RoleAuthoriser([]string{"ADMIN"}),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "createqueuepolicy",
+		Handler:    handler.CreateQueuePolicyHandler,
+		Authoriser: server.OpenToTheWorldAuthoriser(),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "deletequeuepolicy",
+		Handler:    handler.DeleteQueuePolicyHandler,
+		Authoriser: server.OpenToTheWorldAuthoriser(),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "listqueuepolicies",
+		Handler:    handler.ListQueuePoliciesHandler,
+		Authoriser: server.OpenToTheWorldAuthoriser(),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "unsubscribetopic",
+		Handler:    handler.UnsubscribeTopicHandler,
+		Authoriser: server.OpenToTheWorldAuthoriser(),
+	})
+
+	server.Register(&server.Endpoint{
+		Name:       "listsubscriptions",
+		Handler:    handler.ListSubscriptionsHandler,
+		Authoriser: server.OpenToTheWorldAuthoriser(),
+	})
+
 	// only register, don't bind. We'll manually do it in the init() call
 	server.Register(&server.Endpoint{
 		Name:       "com.HailoOSS.kernel.discovery.serviceup",
@@ -62,9 +175,19 @@ func main() {
 	})
 
 	binding.Init()
+	go serveMetrics()
 	server.RegisterPostConnectHandler(binding.PostConnectHandler)
 
 	server.HealthCheck(bindinghealth.HealthCheckId, bindinghealth.BindingHealthCheck())
+	server.HealthCheck(bindinghealth.RebindHealthCheckId, bindinghealth.RebindHealthCheck())
+	for id, check := range bindinghealth.FederationHealthChecks() {
+		server.HealthCheck(id, check)
+	}
+	server.HealthCheck(bindinghealth.TopicHealthCheckId, bindinghealth.TopicHealthCheck())
+	server.HealthCheck(bindinghealth.TopologyHealthCheckId, bindinghealth.TopologyHealthCheck())
+	server.HealthCheck(bindinghealth.FederationLinksHealthCheckId, bindinghealth.FederationLinksHealthCheck())
+	server.HealthCheck(bindinghealth.UnroutableHealthCheckId, bindinghealth.UnroutableHealthCheck())
+	server.HealthCheck(bindinghealth.DirectHealthCheckId, bindinghealth.DirectHealthCheck())
 	server.HealthCheck(zookeeper.HealthCheckId, zookeeper.HealthCheck())
 
 	zookeeper.WaitForConnect(time.Second)
@@ -72,3 +195,14 @@ func main() {
 	// run!
 	server.BindAndRun()
 }
+
+func serveMetrics() {
+	if *metricsAddr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+		log.Errorf("Error serving metrics on %s: %v", *metricsAddr, err)
+	}
+}
@@ -0,0 +1,336 @@
+// Package metrics records counters and histograms, labelled by things like cluster and operation, and exposes them
+// in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	COUNTER   = "counter"
+	HISTOGRAM = "histogram"
+)
+
+// DefaultBuckets are the histogram upper bounds used for durations in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// Default is the registry the New functions register with
+var Default = NewRegistry()
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Bucket struct {
+	UpperBound float64
+	Count      uint64 // cumulative, includes the observations in lower buckets
+}
+
+// Sample is the current value of a metric for one set of label values
+type Sample struct {
+	Name    string
+	Type    string
+	Labels  []Label
+	Value   float64  // counters
+	Count   uint64   // histograms
+	Sum     float64  // histograms
+	Buckets []Bucket // histograms
+}
+
+type metric interface {
+	describe() (name string, help string, kind string)
+	samples() []*Sample
+}
+
+// Registry holds a set of metrics
+type Registry struct {
+	sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+func (r *Registry) register(m metric) {
+	r.Lock()
+	defer r.Unlock()
+	name, _, _ := m.describe()
+	if r.names[name] {
+		panic(fmt.Sprintf("metric %s registered twice", name))
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// sorted returns the metrics sorted by name
+func (r *Registry) sorted() []metric {
+	r.Lock()
+	ms := make([]metric, len(r.metrics))
+	copy(ms, r.metrics)
+	r.Unlock()
+
+	sort.Slice(ms, func(i, j int) bool {
+		ni, _, _ := ms[i].describe()
+		nj, _, _ := ms[j].describe()
+		return ni < nj
+	})
+	return ms
+}
+
+// Samples returns the current value of every metric, sorted by name then labels
+func (r *Registry) Samples() []*Sample {
+	ms := r.sorted()
+	res := make([]*Sample, 0)
+	for _, m := range ms {
+		res = append(res, m.samples()...)
+	}
+	return res
+}
+
+// WriteText writes every metric in the Prometheus text format
+func (r *Registry) WriteText(w io.Writer) error {
+	ms := r.sorted()
+	for _, m := range ms {
+		name, help, kind := m.describe()
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, kind); err != nil {
+			return err
+		}
+		for _, s := range m.samples() {
+			if err := writeSample(w, s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeSample(w io.Writer, s *Sample) error {
+	if s.Type == COUNTER {
+		_, err := fmt.Fprintf(w, "%s%s %s\n", s.Name, formatLabels(s.Labels), formatFloat(s.Value))
+		return err
+	}
+	for _, b := range s.Buckets {
+		labels := append(append([]Label{}, s.Labels...), Label{"le", formatFloat(b.UpperBound)})
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", s.Name, formatLabels(labels), b.Count); err != nil {
+			return err
+		}
+	}
+	labels := append(append([]Label{}, s.Labels...), Label{"le", "+Inf"})
+	if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", s.Name, formatLabels(labels), s.Count); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n", s.Name, formatLabels(s.Labels), formatFloat(s.Sum), s.Name, formatLabels(s.Labels), s.Count)
+	return err
+}
+
+// Handler serves the default registry in the Prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		Default.WriteText(w)
+	})
+}
+
+// Samples returns the current value of every metric in the default registry
+func Samples() []*Sample {
+	return Default.Samples()
+}
+
+func formatLabels(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = l.Name + `="` + escapeLabel(l.Value) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+// series is the state shared by vectors, values keyed by their label values
+type series struct {
+	sync.Mutex
+	name   string
+	help   string
+	labels []string
+}
+
+func (s *series) key(values []string) string {
+	if len(values) != len(s.labels) {
+		panic(fmt.Sprintf("metric %s has labels %v but was given %v", s.name, s.labels, values))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func (s *series) labelPairs(values []string) []Label {
+	res := make([]Label, len(values))
+	for i, v := range values {
+		res[i] = Label{s.labels[i], v}
+	}
+	return res
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CounterVec is a counter for each combination of label values
+type CounterVec struct {
+	series
+	values      map[string]float64
+	labelValues map[string][]string
+}
+
+// NewCounterVec creates a counter with the labels and registers it with the default registry
+func NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	return Default.NewCounterVec(name, help, labels...)
+}
+
+// NewCounterVec creates a counter with the labels and registers it with the registry
+func (r *Registry) NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	c := &CounterVec{series: series{name: name, help: help, labels: labels}, values: make(map[string]float64), labelValues: make(map[string][]string)}
+	r.register(c)
+	return c
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	key := c.key(labelValues)
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.labelValues[key]; !ok {
+		c.labelValues[key] = append([]string{}, labelValues...)
+	}
+	c.values[key] += v
+}
+
+// Value returns the counter for the label values
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+	c.Lock()
+	defer c.Unlock()
+	return c.values[key]
+}
+
+func (c *CounterVec) describe() (string, string, string) {
+	return c.name, c.help, COUNTER
+}
+
+func (c *CounterVec) samples() []*Sample {
+	c.Lock()
+	defer c.Unlock()
+	res := make([]*Sample, 0, len(c.values))
+	for _, key := range sortedKeys(c.labelValues) {
+		res = append(res, &Sample{Name: c.name, Type: COUNTER, Labels: c.labelPairs(c.labelValues[key]), Value: c.values[key]})
+	}
+	return res
+}
+
+type histogramValue struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec is a histogram for each combination of label values
+type HistogramVec struct {
+	series
+	buckets     []float64
+	values      map[string]*histogramValue
+	labelValues map[string][]string
+}
+
+// NewHistogramVec creates a histogram with the bucket upper bounds and labels and registers it with the default
+// registry
+func NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.NewHistogramVec(name, help, buckets, labels...)
+}
+
+// NewHistogramVec creates a histogram with the bucket upper bounds and labels and registers it with the registry
+func (r *Registry) NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	b := append([]float64{}, buckets...)
+	sort.Float64s(b)
+	h := &HistogramVec{series: series{name: name, help: help, labels: labels}, buckets: b, values: make(map[string]*histogramValue), labelValues: make(map[string][]string)}
+	r.register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.Lock()
+	defer h.Unlock()
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+		h.labelValues[key] = append([]string{}, labelValues...)
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		hv.counts[i]++
+	}
+	hv.count++
+	hv.sum += v
+}
+
+// ObserveSince observes the seconds since start
+func (h *HistogramVec) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *HistogramVec) describe() (string, string, string) {
+	return h.name, h.help, HISTOGRAM
+}
+
+func (h *HistogramVec) samples() []*Sample {
+	h.Lock()
+	defer h.Unlock()
+	res := make([]*Sample, 0, len(h.values))
+	for _, key := range sortedKeys(h.labelValues) {
+		hv := h.values[key]
+		s := &Sample{Name: h.name, Type: HISTOGRAM, Labels: h.labelPairs(h.labelValues[key]), Count: hv.count, Sum: hv.sum}
+		var cumulative uint64
+		for i, ub := range h.buckets {
+			cumulative += hv.counts[i]
+			s.Buckets = append(s.Buckets, Bucket{UpperBound: ub, Count: cumulative})
+		}
+		res = append(res, s)
+	}
+	return res
+}
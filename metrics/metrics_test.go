@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("requests_total", "Requests made", "cluster", "op")
+	c.Inc("eu-west-1a", "create_binding")
+	c.Add(2, "eu-west-1a", "create_binding")
+	c.Inc("eu-west-1b", `with "quotes"`)
+	h := r.NewHistogramVec("duration_seconds", "How long requests took", []float64{1, 0.1}, "op")
+	h.Observe(0.05, "get")
+	h.Observe(0.5, "get")
+	h.Observe(5, "get")
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP duration_seconds How long requests took
+# TYPE duration_seconds histogram
+duration_seconds_bucket{op="get",le="0.1"} 1
+duration_seconds_bucket{op="get",le="1"} 2
+duration_seconds_bucket{op="get",le="+Inf"} 3
+duration_seconds_sum{op="get"} 5.55
+duration_seconds_count{op="get"} 3
+# HELP requests_total Requests made
+# TYPE requests_total counter
+requests_total{cluster="eu-west-1a",op="create_binding"} 3
+requests_total{cluster="eu-west-1b",op="with \"quotes\""} 1
+`
+	if buf.String() != expected {
+		t.Errorf("Output incorrect, got\n%s\nexpected\n%s", buf.String(), expected)
+	}
+	if v := c.Value("eu-west-1a", "create_binding"); v != 3 {
+		t.Error("Value incorrect ", v)
+	}
+}
+
+func TestWrongNumberOfLabels(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Should panic with the wrong number of label values")
+		}
+	}()
+	NewRegistry().NewCounterVec("c", "c", "a", "b").Inc("a")
+}
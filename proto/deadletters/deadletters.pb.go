@@ -0,0 +1,211 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/binding-service/proto/deadletters/deadletters.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_binding_deadletters is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/binding-service/proto/deadletters/deadletters.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_binding_deadletters
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Service          *string `protobuf:"bytes,1,opt,name=service" json:"service,omitempty"`
+	Azname           *string `protobuf:"bytes,2,opt,name=azname" json:"azname,omitempty"`
+	Vhost            *string `protobuf:"bytes,3,opt,name=vhost" json:"vhost,omitempty"`
+	Count            *int32  `protobuf:"varint,4,opt,name=count" json:"count,omitempty"`
+	PayloadBytes     *int32  `protobuf:"varint,5,opt,name=payloadBytes" json:"payloadBytes,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetService() string {
+	if m != nil && m.Service != nil {
+		return *m.Service
+	}
+	return ""
+}
+
+func (m *Request) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+func (m *Request) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+func (m *Request) GetCount() int32 {
+	if m != nil && m.Count != nil {
+		return *m.Count
+	}
+	return 0
+}
+
+func (m *Request) GetPayloadBytes() int32 {
+	if m != nil && m.PayloadBytes != nil {
+		return *m.PayloadBytes
+	}
+	return 0
+}
+
+type Response struct {
+	Azname           *string             `protobuf:"bytes,1,req,name=azname" json:"azname,omitempty"`
+	Vhost            *string             `protobuf:"bytes,2,req,name=vhost" json:"vhost,omitempty"`
+	Queue            *string             `protobuf:"bytes,3,req,name=queue" json:"queue,omitempty"`
+	Messages         *int32              `protobuf:"varint,4,req,name=messages" json:"messages,omitempty"`
+	Samples          []*Response_Message `protobuf:"bytes,5,rep,name=samples" json:"samples,omitempty"`
+	XXX_unrecognized []byte              `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+func (m *Response) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+func (m *Response) GetQueue() string {
+	if m != nil && m.Queue != nil {
+		return *m.Queue
+	}
+	return ""
+}
+
+func (m *Response) GetMessages() int32 {
+	if m != nil && m.Messages != nil {
+		return *m.Messages
+	}
+	return 0
+}
+
+func (m *Response) GetSamples() []*Response_Message {
+	if m != nil {
+		return m.Samples
+	}
+	return nil
+}
+
+type Response_Message struct {
+	Exchange         *string `protobuf:"bytes,1,req,name=exchange" json:"exchange,omitempty"`
+	RoutingKey       *string `protobuf:"bytes,2,req,name=routingKey" json:"routingKey,omitempty"`
+	Service          *string `protobuf:"bytes,3,opt,name=service" json:"service,omitempty"`
+	PayloadBytes     *int32  `protobuf:"varint,4,req,name=payloadBytes" json:"payloadBytes,omitempty"`
+	Payload          *string `protobuf:"bytes,5,req,name=payload" json:"payload,omitempty"`
+	PayloadEncoding  *string `protobuf:"bytes,6,opt,name=payloadEncoding" json:"payloadEncoding,omitempty"`
+	Truncated        *bool   `protobuf:"varint,7,req,name=truncated" json:"truncated,omitempty"`
+	MessageId        *string `protobuf:"bytes,8,opt,name=messageId" json:"messageId,omitempty"`
+	ReplyTo          *string `protobuf:"bytes,9,opt,name=replyTo" json:"replyTo,omitempty"`
+	Headers          *string `protobuf:"bytes,10,opt,name=headers" json:"headers,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response_Message) Reset()         { *m = Response_Message{} }
+func (m *Response_Message) String() string { return proto.CompactTextString(m) }
+func (*Response_Message) ProtoMessage()    {}
+
+func (m *Response_Message) GetExchange() string {
+	if m != nil && m.Exchange != nil {
+		return *m.Exchange
+	}
+	return ""
+}
+
+func (m *Response_Message) GetRoutingKey() string {
+	if m != nil && m.RoutingKey != nil {
+		return *m.RoutingKey
+	}
+	return ""
+}
+
+func (m *Response_Message) GetService() string {
+	if m != nil && m.Service != nil {
+		return *m.Service
+	}
+	return ""
+}
+
+func (m *Response_Message) GetPayloadBytes() int32 {
+	if m != nil && m.PayloadBytes != nil {
+		return *m.PayloadBytes
+	}
+	return 0
+}
+
+func (m *Response_Message) GetPayload() string {
+	if m != nil && m.Payload != nil {
+		return *m.Payload
+	}
+	return ""
+}
+
+func (m *Response_Message) GetPayloadEncoding() string {
+	if m != nil && m.PayloadEncoding != nil {
+		return *m.PayloadEncoding
+	}
+	return ""
+}
+
+func (m *Response_Message) GetTruncated() bool {
+	if m != nil && m.Truncated != nil {
+		return *m.Truncated
+	}
+	return false
+}
+
+func (m *Response_Message) GetMessageId() string {
+	if m != nil && m.MessageId != nil {
+		return *m.MessageId
+	}
+	return ""
+}
+
+func (m *Response_Message) GetReplyTo() string {
+	if m != nil && m.ReplyTo != nil {
+		return *m.ReplyTo
+	}
+	return ""
+}
+
+func (m *Response_Message) GetHeaders() string {
+	if m != nil && m.Headers != nil {
+		return *m.Headers
+	}
+	return ""
+}
+
+func init() {
+}
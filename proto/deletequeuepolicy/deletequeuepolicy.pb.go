@@ -0,0 +1,67 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/binding-service/proto/deletequeuepolicy/deletequeuepolicy.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_binding_deletequeuepolicy is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/binding-service/proto/deletequeuepolicy/deletequeuepolicy.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_binding_deletequeuepolicy
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Service          *string `protobuf:"bytes,1,req,name=service" json:"service,omitempty"`
+	Vhost            *string `protobuf:"bytes,2,opt,name=vhost" json:"vhost,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetService() string {
+	if m != nil && m.Service != nil {
+		return *m.Service
+	}
+	return ""
+}
+
+func (m *Request) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+type Response struct {
+	Ok               *bool  `protobuf:"varint,1,req,name=ok" json:"ok,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetOk() bool {
+	if m != nil && m.Ok != nil {
+		return *m.Ok
+	}
+	return false
+}
+
+func init() {
+}
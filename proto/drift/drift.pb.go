@@ -0,0 +1,270 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/binding-service/proto/drift/drift.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_binding_drift is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/binding-service/proto/drift/drift.proto
+
+It has these top-level messages:
+	Request
+	Binding
+	Difference
+	Cluster
+	Response
+*/
+package com_HailoOSS_kernel_binding_drift
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Azname           *string `protobuf:"bytes,1,opt,name=azname" json:"azname,omitempty"`
+	Service          *string `protobuf:"bytes,2,opt,name=service" json:"service,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+func (m *Request) GetService() string {
+	if m != nil && m.Service != nil {
+		return *m.Service
+	}
+	return ""
+}
+
+type Binding struct {
+	Source           *string `protobuf:"bytes,1,req,name=source" json:"source,omitempty"`
+	Vhost            *string `protobuf:"bytes,2,req,name=vhost" json:"vhost,omitempty"`
+	Destination      *string `protobuf:"bytes,3,req,name=destination" json:"destination,omitempty"`
+	DestinationType  *string `protobuf:"bytes,4,req,name=destinationType" json:"destinationType,omitempty"`
+	RoutingKey       *string `protobuf:"bytes,5,req,name=routingKey" json:"routingKey,omitempty"`
+	Arguments        *string `protobuf:"bytes,6,opt,name=arguments" json:"arguments,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Binding) Reset()         { *m = Binding{} }
+func (m *Binding) String() string { return proto.CompactTextString(m) }
+func (*Binding) ProtoMessage()    {}
+
+func (m *Binding) GetSource() string {
+	if m != nil && m.Source != nil {
+		return *m.Source
+	}
+	return ""
+}
+
+func (m *Binding) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+func (m *Binding) GetDestination() string {
+	if m != nil && m.Destination != nil {
+		return *m.Destination
+	}
+	return ""
+}
+
+func (m *Binding) GetDestinationType() string {
+	if m != nil && m.DestinationType != nil {
+		return *m.DestinationType
+	}
+	return ""
+}
+
+func (m *Binding) GetRoutingKey() string {
+	if m != nil && m.RoutingKey != nil {
+		return *m.RoutingKey
+	}
+	return ""
+}
+
+func (m *Binding) GetArguments() string {
+	if m != nil && m.Arguments != nil {
+		return *m.Arguments
+	}
+	return ""
+}
+
+type Difference struct {
+	Azname           *string  `protobuf:"bytes,1,req,name=azname" json:"azname,omitempty"`
+	Kind             *string  `protobuf:"bytes,2,req,name=kind" json:"kind,omitempty"`
+	Problem          *string  `protobuf:"bytes,3,req,name=problem" json:"problem,omitempty"`
+	Service          *string  `protobuf:"bytes,4,opt,name=service" json:"service,omitempty"`
+	Expected         *Binding `protobuf:"bytes,5,opt,name=expected" json:"expected,omitempty"`
+	Actual           *Binding `protobuf:"bytes,6,opt,name=actual" json:"actual,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *Difference) Reset()         { *m = Difference{} }
+func (m *Difference) String() string { return proto.CompactTextString(m) }
+func (*Difference) ProtoMessage()    {}
+
+func (m *Difference) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+func (m *Difference) GetKind() string {
+	if m != nil && m.Kind != nil {
+		return *m.Kind
+	}
+	return ""
+}
+
+func (m *Difference) GetProblem() string {
+	if m != nil && m.Problem != nil {
+		return *m.Problem
+	}
+	return ""
+}
+
+func (m *Difference) GetService() string {
+	if m != nil && m.Service != nil {
+		return *m.Service
+	}
+	return ""
+}
+
+func (m *Difference) GetExpected() *Binding {
+	if m != nil {
+		return m.Expected
+	}
+	return nil
+}
+
+func (m *Difference) GetActual() *Binding {
+	if m != nil {
+		return m.Actual
+	}
+	return nil
+}
+
+type Cluster struct {
+	Azname           *string `protobuf:"bytes,1,req,name=azname" json:"azname,omitempty"`
+	Hostname         *string `protobuf:"bytes,2,req,name=hostname" json:"hostname,omitempty"`
+	Error            *string `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Missing          *int32  `protobuf:"varint,4,req,name=missing" json:"missing,omitempty"`
+	Extra            *int32  `protobuf:"varint,5,req,name=extra" json:"extra,omitempty"`
+	Mismatched       *int32  `protobuf:"varint,6,req,name=mismatched" json:"mismatched,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Cluster) Reset()         { *m = Cluster{} }
+func (m *Cluster) String() string { return proto.CompactTextString(m) }
+func (*Cluster) ProtoMessage()    {}
+
+func (m *Cluster) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+func (m *Cluster) GetHostname() string {
+	if m != nil && m.Hostname != nil {
+		return *m.Hostname
+	}
+	return ""
+}
+
+func (m *Cluster) GetError() string {
+	if m != nil && m.Error != nil {
+		return *m.Error
+	}
+	return ""
+}
+
+func (m *Cluster) GetMissing() int32 {
+	if m != nil && m.Missing != nil {
+		return *m.Missing
+	}
+	return 0
+}
+
+func (m *Cluster) GetExtra() int32 {
+	if m != nil && m.Extra != nil {
+		return *m.Extra
+	}
+	return 0
+}
+
+func (m *Cluster) GetMismatched() int32 {
+	if m != nil && m.Mismatched != nil {
+		return *m.Mismatched
+	}
+	return 0
+}
+
+type Response struct {
+	Differences      []*Difference `protobuf:"bytes,1,rep,name=differences" json:"differences,omitempty"`
+	Clusters         []*Cluster    `protobuf:"bytes,2,rep,name=clusters" json:"clusters,omitempty"`
+	Missing          *int32        `protobuf:"varint,3,req,name=missing" json:"missing,omitempty"`
+	Extra            *int32        `protobuf:"varint,4,req,name=extra" json:"extra,omitempty"`
+	Mismatched       *int32        `protobuf:"varint,5,req,name=mismatched" json:"mismatched,omitempty"`
+	XXX_unrecognized []byte        `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetDifferences() []*Difference {
+	if m != nil {
+		return m.Differences
+	}
+	return nil
+}
+
+func (m *Response) GetClusters() []*Cluster {
+	if m != nil {
+		return m.Clusters
+	}
+	return nil
+}
+
+func (m *Response) GetMissing() int32 {
+	if m != nil && m.Missing != nil {
+		return *m.Missing
+	}
+	return 0
+}
+
+func (m *Response) GetExtra() int32 {
+	if m != nil && m.Extra != nil {
+		return *m.Extra
+	}
+	return 0
+}
+
+func (m *Response) GetMismatched() int32 {
+	if m != nil && m.Mismatched != nil {
+		return *m.Mismatched
+	}
+	return 0
+}
+
+func init() {
+}
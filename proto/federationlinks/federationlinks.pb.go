@@ -0,0 +1,171 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/binding-service/proto/federationlinks/federationlinks.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_binding_federationlinks is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/binding-service/proto/federationlinks/federationlinks.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_binding_federationlinks
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Azname           *string `protobuf:"bytes,1,opt,name=azname" json:"azname,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+type Response struct {
+	Links            []*Response_Link         `protobuf:"bytes,1,rep,name=links" json:"links,omitempty"`
+	Errors           []*Response_ClusterError `protobuf:"bytes,2,rep,name=errors" json:"errors,omitempty"`
+	Checked          *int64                   `protobuf:"varint,3,opt,name=checked" json:"checked,omitempty"`
+	XXX_unrecognized []byte                   `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetLinks() []*Response_Link {
+	if m != nil {
+		return m.Links
+	}
+	return nil
+}
+
+func (m *Response) GetErrors() []*Response_ClusterError {
+	if m != nil {
+		return m.Errors
+	}
+	return nil
+}
+
+func (m *Response) GetChecked() int64 {
+	if m != nil && m.Checked != nil {
+		return *m.Checked
+	}
+	return 0
+}
+
+type Response_Link struct {
+	Azname           *string `protobuf:"bytes,1,req,name=azname" json:"azname,omitempty"`
+	Vhost            *string `protobuf:"bytes,2,req,name=vhost" json:"vhost,omitempty"`
+	Upstream         *string `protobuf:"bytes,3,req,name=upstream" json:"upstream,omitempty"`
+	UpstreamAzname   *string `protobuf:"bytes,4,opt,name=upstreamAzname" json:"upstreamAzname,omitempty"`
+	Exchange         *string `protobuf:"bytes,5,req,name=exchange" json:"exchange,omitempty"`
+	Status           *string `protobuf:"bytes,6,req,name=status" json:"status,omitempty"`
+	Error            *string `protobuf:"bytes,7,opt,name=error" json:"error,omitempty"`
+	Since            *int64  `protobuf:"varint,8,req,name=since" json:"since,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response_Link) Reset()         { *m = Response_Link{} }
+func (m *Response_Link) String() string { return proto.CompactTextString(m) }
+func (*Response_Link) ProtoMessage()    {}
+
+func (m *Response_Link) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+func (m *Response_Link) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+func (m *Response_Link) GetUpstream() string {
+	if m != nil && m.Upstream != nil {
+		return *m.Upstream
+	}
+	return ""
+}
+
+func (m *Response_Link) GetUpstreamAzname() string {
+	if m != nil && m.UpstreamAzname != nil {
+		return *m.UpstreamAzname
+	}
+	return ""
+}
+
+func (m *Response_Link) GetExchange() string {
+	if m != nil && m.Exchange != nil {
+		return *m.Exchange
+	}
+	return ""
+}
+
+func (m *Response_Link) GetStatus() string {
+	if m != nil && m.Status != nil {
+		return *m.Status
+	}
+	return ""
+}
+
+func (m *Response_Link) GetError() string {
+	if m != nil && m.Error != nil {
+		return *m.Error
+	}
+	return ""
+}
+
+func (m *Response_Link) GetSince() int64 {
+	if m != nil && m.Since != nil {
+		return *m.Since
+	}
+	return 0
+}
+
+type Response_ClusterError struct {
+	Azname           *string `protobuf:"bytes,1,req,name=azname" json:"azname,omitempty"`
+	Error            *string `protobuf:"bytes,2,req,name=error" json:"error,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response_ClusterError) Reset()         { *m = Response_ClusterError{} }
+func (m *Response_ClusterError) String() string { return proto.CompactTextString(m) }
+func (*Response_ClusterError) ProtoMessage()    {}
+
+func (m *Response_ClusterError) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+func (m *Response_ClusterError) GetError() string {
+	if m != nil && m.Error != nil {
+		return *m.Error
+	}
+	return ""
+}
+
+func init() {
+}
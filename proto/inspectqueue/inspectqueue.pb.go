@@ -0,0 +1,355 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/binding-service/proto/inspectqueue/inspectqueue.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_binding_inspectqueue is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/binding-service/proto/inspectqueue/inspectqueue.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_binding_inspectqueue
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Queue            *string `protobuf:"bytes,1,req,name=queue" json:"queue,omitempty"`
+	Azname           *string `protobuf:"bytes,2,opt,name=azname" json:"azname,omitempty"`
+	Vhost            *string `protobuf:"bytes,3,opt,name=vhost" json:"vhost,omitempty"`
+	Samples          *int32  `protobuf:"varint,4,opt,name=samples" json:"samples,omitempty"`
+	PayloadBytes     *int32  `protobuf:"varint,5,opt,name=payloadBytes" json:"payloadBytes,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetQueue() string {
+	if m != nil && m.Queue != nil {
+		return *m.Queue
+	}
+	return ""
+}
+
+func (m *Request) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+func (m *Request) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+func (m *Request) GetSamples() int32 {
+	if m != nil && m.Samples != nil {
+		return *m.Samples
+	}
+	return 0
+}
+
+func (m *Request) GetPayloadBytes() int32 {
+	if m != nil && m.PayloadBytes != nil {
+		return *m.PayloadBytes
+	}
+	return 0
+}
+
+type Response struct {
+	Azname                 *string             `protobuf:"bytes,1,req,name=azname" json:"azname,omitempty"`
+	Hostname               *string             `protobuf:"bytes,2,req,name=hostname" json:"hostname,omitempty"`
+	Vhost                  *string             `protobuf:"bytes,3,req,name=vhost" json:"vhost,omitempty"`
+	Queue                  *string             `protobuf:"bytes,4,req,name=queue" json:"queue,omitempty"`
+	Node                   *string             `protobuf:"bytes,5,opt,name=node" json:"node,omitempty"`
+	Messages               *int32              `protobuf:"varint,6,req,name=messages" json:"messages,omitempty"`
+	MessagesReady          *int32              `protobuf:"varint,7,req,name=messagesReady" json:"messagesReady,omitempty"`
+	MessagesUnacknowledged *int32              `protobuf:"varint,8,req,name=messagesUnacknowledged" json:"messagesUnacknowledged,omitempty"`
+	PublishRate            *float64            `protobuf:"fixed64,9,req,name=publishRate" json:"publishRate,omitempty"`
+	DeliverRate            *float64            `protobuf:"fixed64,10,req,name=deliverRate" json:"deliverRate,omitempty"`
+	Consumers              *int32              `protobuf:"varint,11,req,name=consumers" json:"consumers,omitempty"`
+	IdleSince              *string             `protobuf:"bytes,12,opt,name=idleSince" json:"idleSince,omitempty"`
+	Policy                 *string             `protobuf:"bytes,13,opt,name=policy" json:"policy,omitempty"`
+	Durable                *bool               `protobuf:"varint,14,req,name=durable" json:"durable,omitempty"`
+	AutoDelete             *bool               `protobuf:"varint,15,req,name=autoDelete" json:"autoDelete,omitempty"`
+	Arguments              *string             `protobuf:"bytes,16,opt,name=arguments" json:"arguments,omitempty"`
+	Bindings               []*Response_Binding `protobuf:"bytes,17,rep,name=bindings" json:"bindings,omitempty"`
+	Samples                []*Response_Message `protobuf:"bytes,18,rep,name=samples" json:"samples,omitempty"`
+	XXX_unrecognized       []byte              `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+func (m *Response) GetHostname() string {
+	if m != nil && m.Hostname != nil {
+		return *m.Hostname
+	}
+	return ""
+}
+
+func (m *Response) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+func (m *Response) GetQueue() string {
+	if m != nil && m.Queue != nil {
+		return *m.Queue
+	}
+	return ""
+}
+
+func (m *Response) GetNode() string {
+	if m != nil && m.Node != nil {
+		return *m.Node
+	}
+	return ""
+}
+
+func (m *Response) GetMessages() int32 {
+	if m != nil && m.Messages != nil {
+		return *m.Messages
+	}
+	return 0
+}
+
+func (m *Response) GetMessagesReady() int32 {
+	if m != nil && m.MessagesReady != nil {
+		return *m.MessagesReady
+	}
+	return 0
+}
+
+func (m *Response) GetMessagesUnacknowledged() int32 {
+	if m != nil && m.MessagesUnacknowledged != nil {
+		return *m.MessagesUnacknowledged
+	}
+	return 0
+}
+
+func (m *Response) GetPublishRate() float64 {
+	if m != nil && m.PublishRate != nil {
+		return *m.PublishRate
+	}
+	return 0
+}
+
+func (m *Response) GetDeliverRate() float64 {
+	if m != nil && m.DeliverRate != nil {
+		return *m.DeliverRate
+	}
+	return 0
+}
+
+func (m *Response) GetConsumers() int32 {
+	if m != nil && m.Consumers != nil {
+		return *m.Consumers
+	}
+	return 0
+}
+
+func (m *Response) GetIdleSince() string {
+	if m != nil && m.IdleSince != nil {
+		return *m.IdleSince
+	}
+	return ""
+}
+
+func (m *Response) GetPolicy() string {
+	if m != nil && m.Policy != nil {
+		return *m.Policy
+	}
+	return ""
+}
+
+func (m *Response) GetDurable() bool {
+	if m != nil && m.Durable != nil {
+		return *m.Durable
+	}
+	return false
+}
+
+func (m *Response) GetAutoDelete() bool {
+	if m != nil && m.AutoDelete != nil {
+		return *m.AutoDelete
+	}
+	return false
+}
+
+func (m *Response) GetArguments() string {
+	if m != nil && m.Arguments != nil {
+		return *m.Arguments
+	}
+	return ""
+}
+
+func (m *Response) GetBindings() []*Response_Binding {
+	if m != nil {
+		return m.Bindings
+	}
+	return nil
+}
+
+func (m *Response) GetSamples() []*Response_Message {
+	if m != nil {
+		return m.Samples
+	}
+	return nil
+}
+
+type Response_Binding struct {
+	Source           *string `protobuf:"bytes,1,req,name=source" json:"source,omitempty"`
+	RoutingKey       *string `protobuf:"bytes,2,req,name=routingKey" json:"routingKey,omitempty"`
+	Arguments        *string `protobuf:"bytes,3,opt,name=arguments" json:"arguments,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response_Binding) Reset()         { *m = Response_Binding{} }
+func (m *Response_Binding) String() string { return proto.CompactTextString(m) }
+func (*Response_Binding) ProtoMessage()    {}
+
+func (m *Response_Binding) GetSource() string {
+	if m != nil && m.Source != nil {
+		return *m.Source
+	}
+	return ""
+}
+
+func (m *Response_Binding) GetRoutingKey() string {
+	if m != nil && m.RoutingKey != nil {
+		return *m.RoutingKey
+	}
+	return ""
+}
+
+func (m *Response_Binding) GetArguments() string {
+	if m != nil && m.Arguments != nil {
+		return *m.Arguments
+	}
+	return ""
+}
+
+type Response_Message struct {
+	Exchange         *string `protobuf:"bytes,1,req,name=exchange" json:"exchange,omitempty"`
+	RoutingKey       *string `protobuf:"bytes,2,req,name=routingKey" json:"routingKey,omitempty"`
+	Redelivered      *bool   `protobuf:"varint,3,req,name=redelivered" json:"redelivered,omitempty"`
+	PayloadBytes     *int32  `protobuf:"varint,4,req,name=payloadBytes" json:"payloadBytes,omitempty"`
+	Payload          *string `protobuf:"bytes,5,req,name=payload" json:"payload,omitempty"`
+	PayloadEncoding  *string `protobuf:"bytes,6,opt,name=payloadEncoding" json:"payloadEncoding,omitempty"`
+	Truncated        *bool   `protobuf:"varint,7,req,name=truncated" json:"truncated,omitempty"`
+	MessageId        *string `protobuf:"bytes,8,opt,name=messageId" json:"messageId,omitempty"`
+	ReplyTo          *string `protobuf:"bytes,9,opt,name=replyTo" json:"replyTo,omitempty"`
+	ContentType      *string `protobuf:"bytes,10,opt,name=contentType" json:"contentType,omitempty"`
+	Headers          *string `protobuf:"bytes,11,opt,name=headers" json:"headers,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response_Message) Reset()         { *m = Response_Message{} }
+func (m *Response_Message) String() string { return proto.CompactTextString(m) }
+func (*Response_Message) ProtoMessage()    {}
+
+func (m *Response_Message) GetExchange() string {
+	if m != nil && m.Exchange != nil {
+		return *m.Exchange
+	}
+	return ""
+}
+
+func (m *Response_Message) GetRoutingKey() string {
+	if m != nil && m.RoutingKey != nil {
+		return *m.RoutingKey
+	}
+	return ""
+}
+
+func (m *Response_Message) GetRedelivered() bool {
+	if m != nil && m.Redelivered != nil {
+		return *m.Redelivered
+	}
+	return false
+}
+
+func (m *Response_Message) GetPayloadBytes() int32 {
+	if m != nil && m.PayloadBytes != nil {
+		return *m.PayloadBytes
+	}
+	return 0
+}
+
+func (m *Response_Message) GetPayload() string {
+	if m != nil && m.Payload != nil {
+		return *m.Payload
+	}
+	return ""
+}
+
+func (m *Response_Message) GetPayloadEncoding() string {
+	if m != nil && m.PayloadEncoding != nil {
+		return *m.PayloadEncoding
+	}
+	return ""
+}
+
+func (m *Response_Message) GetTruncated() bool {
+	if m != nil && m.Truncated != nil {
+		return *m.Truncated
+	}
+	return false
+}
+
+func (m *Response_Message) GetMessageId() string {
+	if m != nil && m.MessageId != nil {
+		return *m.MessageId
+	}
+	return ""
+}
+
+func (m *Response_Message) GetReplyTo() string {
+	if m != nil && m.ReplyTo != nil {
+		return *m.ReplyTo
+	}
+	return ""
+}
+
+func (m *Response_Message) GetContentType() string {
+	if m != nil && m.ContentType != nil {
+		return *m.ContentType
+	}
+	return ""
+}
+
+func (m *Response_Message) GetHeaders() string {
+	if m != nil && m.Headers != nil {
+		return *m.Headers
+	}
+	return ""
+}
+
+func init() {
+}
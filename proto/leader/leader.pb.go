@@ -0,0 +1,91 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/binding-service/proto/leader/leader.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_binding_leader is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/binding-service/proto/leader/leader.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_binding_leader
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Azname           *string `protobuf:"bytes,1,opt,name=azname" json:"azname,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+type Response struct {
+	InstanceId       *string `protobuf:"bytes,1,opt,name=instanceId" json:"instanceId,omitempty"`
+	Hostname         *string `protobuf:"bytes,2,opt,name=hostname" json:"hostname,omitempty"`
+	Azname           *string `protobuf:"bytes,3,opt,name=azname" json:"azname,omitempty"`
+	Since            *int64  `protobuf:"varint,4,opt,name=since" json:"since,omitempty"`
+	IsLeader         *bool   `protobuf:"varint,5,req,name=isLeader" json:"isLeader,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetInstanceId() string {
+	if m != nil && m.InstanceId != nil {
+		return *m.InstanceId
+	}
+	return ""
+}
+
+func (m *Response) GetHostname() string {
+	if m != nil && m.Hostname != nil {
+		return *m.Hostname
+	}
+	return ""
+}
+
+func (m *Response) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+func (m *Response) GetSince() int64 {
+	if m != nil && m.Since != nil {
+		return *m.Since
+	}
+	return 0
+}
+
+func (m *Response) GetIsLeader() bool {
+	if m != nil && m.IsLeader != nil {
+		return *m.IsLeader
+	}
+	return false
+}
+
+func init() {
+}
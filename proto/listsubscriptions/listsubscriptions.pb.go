@@ -0,0 +1,107 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/binding-service/proto/listsubscriptions/listsubscriptions.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_binding_listsubscriptions is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/binding-service/proto/listsubscriptions/listsubscriptions.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_binding_listsubscriptions
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Queue            *string `protobuf:"bytes,1,opt,name=queue" json:"queue,omitempty"`
+	Topic            *string `protobuf:"bytes,2,opt,name=topic" json:"topic,omitempty"`
+	Vhost            *string `protobuf:"bytes,3,opt,name=vhost" json:"vhost,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetQueue() string {
+	if m != nil && m.Queue != nil {
+		return *m.Queue
+	}
+	return ""
+}
+
+func (m *Request) GetTopic() string {
+	if m != nil && m.Topic != nil {
+		return *m.Topic
+	}
+	return ""
+}
+
+func (m *Request) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+type Response struct {
+	Subscriptions    []*Response_Subscription `protobuf:"bytes,1,rep,name=subscriptions" json:"subscriptions,omitempty"`
+	XXX_unrecognized []byte                   `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetSubscriptions() []*Response_Subscription {
+	if m != nil {
+		return m.Subscriptions
+	}
+	return nil
+}
+
+type Response_Subscription struct {
+	Vhost            *string `protobuf:"bytes,1,req,name=vhost" json:"vhost,omitempty"`
+	Queue            *string `protobuf:"bytes,2,req,name=queue" json:"queue,omitempty"`
+	Topic            *string `protobuf:"bytes,3,req,name=topic" json:"topic,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response_Subscription) Reset()         { *m = Response_Subscription{} }
+func (m *Response_Subscription) String() string { return proto.CompactTextString(m) }
+func (*Response_Subscription) ProtoMessage()    {}
+
+func (m *Response_Subscription) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+func (m *Response_Subscription) GetQueue() string {
+	if m != nil && m.Queue != nil {
+		return *m.Queue
+	}
+	return ""
+}
+
+func (m *Response_Subscription) GetTopic() string {
+	if m != nil && m.Topic != nil {
+		return *m.Topic
+	}
+	return ""
+}
+
+func init() {
+}
@@ -0,0 +1,123 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/binding-service/proto/nodehealth/nodehealth.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_binding_nodehealth is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/binding-service/proto/nodehealth/nodehealth.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_binding_nodehealth
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Azname           *string `protobuf:"bytes,1,opt,name=azname" json:"azname,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+type Response struct {
+	Nodes            []*Response_Node `protobuf:"bytes,1,rep,name=nodes" json:"nodes,omitempty"`
+	XXX_unrecognized []byte           `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetNodes() []*Response_Node {
+	if m != nil {
+		return m.Nodes
+	}
+	return nil
+}
+
+type Response_Node struct {
+	Hostname         *string `protobuf:"bytes,1,req,name=hostname" json:"hostname,omitempty"`
+	Azname           *string `protobuf:"bytes,2,req,name=azname" json:"azname,omitempty"`
+	Healthy          *bool   `protobuf:"varint,3,req,name=healthy" json:"healthy,omitempty"`
+	Failures         *int32  `protobuf:"varint,4,req,name=failures" json:"failures,omitempty"`
+	LastFailure      *int64  `protobuf:"varint,5,opt,name=lastFailure" json:"lastFailure,omitempty"`
+	LastSuccess      *int64  `protobuf:"varint,6,opt,name=lastSuccess" json:"lastSuccess,omitempty"`
+	LastError        *string `protobuf:"bytes,7,opt,name=lastError" json:"lastError,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response_Node) Reset()         { *m = Response_Node{} }
+func (m *Response_Node) String() string { return proto.CompactTextString(m) }
+func (*Response_Node) ProtoMessage()    {}
+
+func (m *Response_Node) GetHostname() string {
+	if m != nil && m.Hostname != nil {
+		return *m.Hostname
+	}
+	return ""
+}
+
+func (m *Response_Node) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+func (m *Response_Node) GetHealthy() bool {
+	if m != nil && m.Healthy != nil {
+		return *m.Healthy
+	}
+	return false
+}
+
+func (m *Response_Node) GetFailures() int32 {
+	if m != nil && m.Failures != nil {
+		return *m.Failures
+	}
+	return 0
+}
+
+func (m *Response_Node) GetLastFailure() int64 {
+	if m != nil && m.LastFailure != nil {
+		return *m.LastFailure
+	}
+	return 0
+}
+
+func (m *Response_Node) GetLastSuccess() int64 {
+	if m != nil && m.LastSuccess != nil {
+		return *m.LastSuccess
+	}
+	return 0
+}
+
+func (m *Response_Node) GetLastError() string {
+	if m != nil && m.LastError != nil {
+		return *m.LastError
+	}
+	return ""
+}
+
+func init() {
+}
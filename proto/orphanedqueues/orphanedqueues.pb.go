@@ -0,0 +1,107 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/binding-service/proto/orphanedqueues/orphanedqueues.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_binding_orphanedqueues is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/binding-service/proto/orphanedqueues/orphanedqueues.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_binding_orphanedqueues
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+type Response struct {
+	Azname           *string           `protobuf:"bytes,1,req,name=azname" json:"azname,omitempty"`
+	Queues           []*Response_Queue `protobuf:"bytes,2,rep,name=queues" json:"queues,omitempty"`
+	XXX_unrecognized []byte            `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+func (m *Response) GetQueues() []*Response_Queue {
+	if m != nil {
+		return m.Queues
+	}
+	return nil
+}
+
+type Response_Queue struct {
+	Vhost            *string `protobuf:"bytes,1,req,name=vhost" json:"vhost,omitempty"`
+	Name             *string `protobuf:"bytes,2,req,name=name" json:"name,omitempty"`
+	Messages         *int32  `protobuf:"varint,3,req,name=messages" json:"messages,omitempty"`
+	FirstSeen        *int64  `protobuf:"varint,4,req,name=firstSeen" json:"firstSeen,omitempty"`
+	Action           *string `protobuf:"bytes,5,req,name=action" json:"action,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response_Queue) Reset()         { *m = Response_Queue{} }
+func (m *Response_Queue) String() string { return proto.CompactTextString(m) }
+func (*Response_Queue) ProtoMessage()    {}
+
+func (m *Response_Queue) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+func (m *Response_Queue) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *Response_Queue) GetMessages() int32 {
+	if m != nil && m.Messages != nil {
+		return *m.Messages
+	}
+	return 0
+}
+
+func (m *Response_Queue) GetFirstSeen() int64 {
+	if m != nil && m.FirstSeen != nil {
+		return *m.FirstSeen
+	}
+	return 0
+}
+
+func (m *Response_Queue) GetAction() string {
+	if m != nil && m.Action != nil {
+		return *m.Action
+	}
+	return ""
+}
+
+func init() {
+}
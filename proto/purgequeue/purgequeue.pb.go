@@ -0,0 +1,115 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/binding-service/proto/purgequeue/purgequeue.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_binding_purgequeue is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/binding-service/proto/purgequeue/purgequeue.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_binding_purgequeue
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Queue            *string `protobuf:"bytes,1,req,name=queue" json:"queue,omitempty"`
+	Azname           *string `protobuf:"bytes,2,opt,name=azname" json:"azname,omitempty"`
+	Vhost            *string `protobuf:"bytes,3,opt,name=vhost" json:"vhost,omitempty"`
+	Token            *string `protobuf:"bytes,4,opt,name=token" json:"token,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetQueue() string {
+	if m != nil && m.Queue != nil {
+		return *m.Queue
+	}
+	return ""
+}
+
+func (m *Request) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+func (m *Request) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+func (m *Request) GetToken() string {
+	if m != nil && m.Token != nil {
+		return *m.Token
+	}
+	return ""
+}
+
+type Response struct {
+	Purged           *bool   `protobuf:"varint,1,req,name=purged" json:"purged,omitempty"`
+	Token            *string `protobuf:"bytes,2,opt,name=token" json:"token,omitempty"`
+	Messages         *int32  `protobuf:"varint,3,req,name=messages" json:"messages,omitempty"`
+	Azname           *string `protobuf:"bytes,4,req,name=azname" json:"azname,omitempty"`
+	Vhost            *string `protobuf:"bytes,5,req,name=vhost" json:"vhost,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetPurged() bool {
+	if m != nil && m.Purged != nil {
+		return *m.Purged
+	}
+	return false
+}
+
+func (m *Response) GetToken() string {
+	if m != nil && m.Token != nil {
+		return *m.Token
+	}
+	return ""
+}
+
+func (m *Response) GetMessages() int32 {
+	if m != nil && m.Messages != nil {
+		return *m.Messages
+	}
+	return 0
+}
+
+func (m *Response) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+func (m *Response) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+func init() {
+}
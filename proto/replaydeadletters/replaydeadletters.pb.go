@@ -0,0 +1,123 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/binding-service/proto/replaydeadletters/replaydeadletters.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_binding_replaydeadletters is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/binding-service/proto/replaydeadletters/replaydeadletters.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_binding_replaydeadletters
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Service          *string `protobuf:"bytes,1,opt,name=service" json:"service,omitempty"`
+	Azname           *string `protobuf:"bytes,2,opt,name=azname" json:"azname,omitempty"`
+	Vhost            *string `protobuf:"bytes,3,opt,name=vhost" json:"vhost,omitempty"`
+	Count            *int32  `protobuf:"varint,4,opt,name=count" json:"count,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetService() string {
+	if m != nil && m.Service != nil {
+		return *m.Service
+	}
+	return ""
+}
+
+func (m *Request) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+func (m *Request) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+func (m *Request) GetCount() int32 {
+	if m != nil && m.Count != nil {
+		return *m.Count
+	}
+	return 0
+}
+
+type Response struct {
+	Azname           *string `protobuf:"bytes,1,req,name=azname" json:"azname,omitempty"`
+	Vhost            *string `protobuf:"bytes,2,req,name=vhost" json:"vhost,omitempty"`
+	Queue            *string `protobuf:"bytes,3,req,name=queue" json:"queue,omitempty"`
+	Replayed         *int32  `protobuf:"varint,4,req,name=replayed" json:"replayed,omitempty"`
+	Unrouted         *int32  `protobuf:"varint,5,req,name=unrouted" json:"unrouted,omitempty"`
+	Failed           *int32  `protobuf:"varint,6,req,name=failed" json:"failed,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetAzname() string {
+	if m != nil && m.Azname != nil {
+		return *m.Azname
+	}
+	return ""
+}
+
+func (m *Response) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+func (m *Response) GetQueue() string {
+	if m != nil && m.Queue != nil {
+		return *m.Queue
+	}
+	return ""
+}
+
+func (m *Response) GetReplayed() int32 {
+	if m != nil && m.Replayed != nil {
+		return *m.Replayed
+	}
+	return 0
+}
+
+func (m *Response) GetUnrouted() int32 {
+	if m != nil && m.Unrouted != nil {
+		return *m.Unrouted
+	}
+	return 0
+}
+
+func (m *Response) GetFailed() int32 {
+	if m != nil && m.Failed != nil {
+		return *m.Failed
+	}
+	return 0
+}
+
+func init() {
+}
@@ -10,6 +10,8 @@ It is generated from these files:
 
 It has these top-level messages:
 	BindingRule
+	DeadLetterRule
+	QueuePolicyRule
 */
 package com_HailoOSS_kernel_binding
 
@@ -26,6 +28,7 @@ type BindingRule struct {
 	Service          *string `protobuf:"bytes,1,req,name=service" json:"service,omitempty"`
 	Version          *string `protobuf:"bytes,2,req,name=version" json:"version,omitempty"`
 	Weight           *int32  `protobuf:"varint,3,req,name=weight" json:"weight,omitempty"`
+	Vhost            *string `protobuf:"bytes,4,opt,name=vhost" json:"vhost,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -54,5 +57,108 @@ func (m *BindingRule) GetWeight() int32 {
 	return 0
 }
 
+func (m *BindingRule) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+type DeadLetterRule struct {
+	Service          *string `protobuf:"bytes,1,req,name=service" json:"service,omitempty"`
+	Vhost            *string `protobuf:"bytes,2,opt,name=vhost" json:"vhost,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *DeadLetterRule) Reset()         { *m = DeadLetterRule{} }
+func (m *DeadLetterRule) String() string { return proto.CompactTextString(m) }
+func (*DeadLetterRule) ProtoMessage()    {}
+
+func (m *DeadLetterRule) GetService() string {
+	if m != nil && m.Service != nil {
+		return *m.Service
+	}
+	return ""
+}
+
+func (m *DeadLetterRule) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+type QueuePolicyRule struct {
+	Service          *string `protobuf:"bytes,1,req,name=service" json:"service,omitempty"`
+	Vhost            *string `protobuf:"bytes,2,opt,name=vhost" json:"vhost,omitempty"`
+	MessageTtl       *int64  `protobuf:"varint,3,opt,name=messageTtl" json:"messageTtl,omitempty"`
+	MaxLength        *int64  `protobuf:"varint,4,opt,name=maxLength" json:"maxLength,omitempty"`
+	Overflow         *string `protobuf:"bytes,5,opt,name=overflow" json:"overflow,omitempty"`
+	HaMode           *string `protobuf:"bytes,6,opt,name=haMode" json:"haMode,omitempty"`
+	HaParams         *int64  `protobuf:"varint,7,opt,name=haParams" json:"haParams,omitempty"`
+	DeadLetter       *bool   `protobuf:"varint,8,opt,name=deadLetter" json:"deadLetter,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *QueuePolicyRule) Reset()         { *m = QueuePolicyRule{} }
+func (m *QueuePolicyRule) String() string { return proto.CompactTextString(m) }
+func (*QueuePolicyRule) ProtoMessage()    {}
+
+func (m *QueuePolicyRule) GetService() string {
+	if m != nil && m.Service != nil {
+		return *m.Service
+	}
+	return ""
+}
+
+func (m *QueuePolicyRule) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
+func (m *QueuePolicyRule) GetMessageTtl() int64 {
+	if m != nil && m.MessageTtl != nil {
+		return *m.MessageTtl
+	}
+	return 0
+}
+
+func (m *QueuePolicyRule) GetMaxLength() int64 {
+	if m != nil && m.MaxLength != nil {
+		return *m.MaxLength
+	}
+	return 0
+}
+
+func (m *QueuePolicyRule) GetOverflow() string {
+	if m != nil && m.Overflow != nil {
+		return *m.Overflow
+	}
+	return ""
+}
+
+func (m *QueuePolicyRule) GetHaMode() string {
+	if m != nil && m.HaMode != nil {
+		return *m.HaMode
+	}
+	return ""
+}
+
+func (m *QueuePolicyRule) GetHaParams() int64 {
+	if m != nil && m.HaParams != nil {
+		return *m.HaParams
+	}
+	return 0
+}
+
+func (m *QueuePolicyRule) GetDeadLetter() bool {
+	if m != nil && m.DeadLetter != nil {
+		return *m.DeadLetter
+	}
+	return false
+}
+
 func init() {
 }
@@ -26,6 +26,7 @@ var _ = math.Inf
 type Request struct {
 	Topic            *string `protobuf:"bytes,1,req,name=topic" json:"topic,omitempty"`
 	Queue            *string `protobuf:"bytes,2,req,name=queue" json:"queue,omitempty"`
+	Vhost            *string `protobuf:"bytes,3,opt,name=vhost" json:"vhost,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -47,6 +48,13 @@ func (m *Request) GetQueue() string {
 	return ""
 }
 
+func (m *Request) GetVhost() string {
+	if m != nil && m.Vhost != nil {
+		return *m.Vhost
+	}
+	return ""
+}
+
 type Response struct {
 	Ok               *bool  `protobuf:"varint,1,req,name=ok" json:"ok,omitempty"`
 	XXX_unrecognized []byte `json:"-"`
@@ -0,0 +1,319 @@
+package rabbit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/HailoOSS/binding-service/util"
+	"github.com/HailoOSS/platform/raven"
+	log "github.com/cihub/seelog"
+)
+
+var (
+	apiScheme   = flag.String("rabbit_api_scheme", "http", "Scheme of the RabbitMQ management API, http or https")
+	apiPort     = flag.Int("rabbit_api_port", 0, "Port of the RabbitMQ management API, defaults to the admin port")
+	apiUsername = flag.String("rabbit_api_username", "", "Username for the RabbitMQ management API, defaults to the raven username")
+	apiPassword = flag.String("rabbit_api_password", "", "Password for the RabbitMQ management API, defaults to the raven password")
+
+	defaultClient *Client
+	defaultOnce   sync.Once
+)
+
+// maximum amount of an error response body to keep
+const maxErrorBody = 4096
+
+// matches the userinfo of uris such as federation upstreams so it can be kept out of the logs
+var userinfoRegexp = regexp.MustCompile(`://[^/@"\s]+@`)
+
+// Client talks to the RabbitMQ management API of any host. Calls are retried according to their operation's
+// RetryPolicy and the number of concurrent calls to each host is limited. If a host's node is failing its call is
+// retried on another node of the same cluster.
+type Client struct {
+	Scheme     string
+	Port       int // used for hosts which don't specify a port
+	Username   string
+	Password   string
+	HttpClient *http.Client
+	// Credentials looks up the credentials for a host, the client's username and password are used if it's nil or
+	// doesn't find any
+	Credentials func(host string) (username string, password string, ok bool)
+	// Cluster returns the nodes of the host's cluster, calls only go to the host itself if it's nil
+	Cluster func(host string) []string
+	// ClusterName returns the name of the host's cluster for metrics, they're labelled unknown if it's nil
+	ClusterName func(host string) string
+	Nodes       *NodeSelector
+	limiter     *hostLimiter
+}
+
+func NewClient(scheme string, port int, username string, password string) *Client {
+	return &Client{
+		Scheme:     scheme,
+		Port:       port,
+		Username:   username,
+		Password:   password,
+		HttpClient: &http.Client{},
+		Nodes:      NewNodeSelector(),
+		limiter:    newHostLimiter(),
+	}
+}
+
+// DefaultClient returns the client configured by the command line flags
+func DefaultClient() *Client {
+	defaultOnce.Do(func() {
+		port := *apiPort
+		if port == 0 {
+			port = *raven.ADMINPORT
+		}
+		username, password := *apiUsername, *apiPassword
+		if username == "" {
+			username, password = raven.USERNAME, raven.PASSWORD
+		}
+		defaultClient = NewClient(*apiScheme, port, username, password)
+		defaultClient.Credentials = secretsCredentials
+		defaultClient.Cluster = clusterNodes
+		defaultClient.ClusterName = clusterName
+		if *apiScheme == "https" {
+			config, err := NewTLSConfig(*apiCAFile, *apiCertFile, *apiKeyFile)
+			if err != nil {
+				// carry on with the system roots, requests will fail verification if they're needed
+				log.Criticalf("Error configuring tls for the management API: %v", err)
+			} else {
+				defaultClient.HttpClient = &http.Client{Transport: &http.Transport{
+					Proxy:           http.ProxyFromEnvironment,
+					TLSClientConfig: config,
+				}}
+			}
+		}
+	})
+	return defaultClient
+}
+
+// secretsCredentials looks up the host's credentials in the secrets file
+func secretsCredentials(host string) (string, string, bool) {
+	creds, ok := util.GetRabbitCredentials(host)
+	if !ok {
+		return "", "", false
+	}
+	return creds.Username, creds.Password, true
+}
+
+// clusterNodes returns the nodes of the host's cluster from the rabbit hosts config
+func clusterNodes(host string) []string {
+	cluster, ok := util.GetRabbitCluster(host)
+	if !ok {
+		return nil
+	}
+	return cluster.ManagementHosts()
+}
+
+// clusterName returns the AZ of the host's cluster from the rabbit hosts config
+func clusterName(host string) string {
+	cluster, ok := util.GetRabbitCluster(host)
+	if !ok {
+		return ""
+	}
+	return cluster.AzName
+}
+
+// nodesFor returns the nodes of the host's cluster in the order they should be tried, with the port added
+func (c *Client) nodesFor(host string) []string {
+	host = c.hostPort(host)
+	if c.Cluster == nil {
+		return []string{host}
+	}
+	nodes := make([]string, 0)
+	member := false
+	for _, n := range c.Cluster(host) {
+		n = c.hostPort(n)
+		member = member || n == host
+		nodes = append(nodes, n)
+	}
+	if !member {
+		return []string{host}
+	}
+	return c.Nodes.Order(host, nodes)
+}
+
+// NodeHealth returns the health of the nodes of the host's cluster
+func (c *Client) NodeHealth(hosts []string) []*NodeHealth {
+	hps := make([]string, len(hosts))
+	for i, h := range hosts {
+		hps[i] = c.hostPort(h)
+	}
+	return c.Nodes.Health(hps)
+}
+
+// PickNode returns the healthiest of the hosts, which should all be nodes of the same cluster
+func (c *Client) PickNode(hosts []string) string {
+	hps := make([]string, len(hosts))
+	for i, h := range hosts {
+		hps[i] = c.hostPort(h)
+	}
+	return c.Nodes.Pick(hps)
+}
+
+// nodeFailed returns whether a call failed because of the node rather than the request
+func nodeFailed(rsp *http.Response, err error) bool {
+	if err == nil {
+		return false
+	}
+	if rsp != nil {
+		return rsp.StatusCode >= 500
+	}
+	return true
+}
+
+// credentials returns the username and password to use for the host
+func (c *Client) credentials(host string) (string, string) {
+	if c.Credentials != nil {
+		if username, password, ok := c.Credentials(host); ok {
+			return username, password
+		}
+	}
+	return c.Username, c.Password
+}
+
+// redact removes any credentials from the params so they can be logged
+func redact(params interface{}) string {
+	if params == nil {
+		return ""
+	}
+	b, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Sprintf("%T", params)
+	}
+	return userinfoRegexp.ReplaceAllString(string(b), "://xxxxx@")
+}
+
+// APIError is returned when the management API responds with an unsuccessful status code
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s returned status code %d: %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+// IsNotFound returns whether the error is a 404 from the management API
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// hostPort adds the client's port to the host if it doesn't have one
+func (c *Client) hostPort(host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, strconv.Itoa(c.Port))
+}
+
+func (c *Client) makeURL(host string, path string) *url.URL {
+	// have to do this cruft to get away with %2F in the url path
+	return &url.URL{Scheme: c.Scheme, Host: host, Opaque: "//" + host + "/api/" + path}
+}
+
+func (c *Client) createRequest(u *url.URL, method string, params interface{}) (*http.Request, error) {
+	var rdr io.Reader = nil
+	if params != nil {
+		body, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		rdr = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u.Scheme+":"+u.Opaque, rdr)
+	if err != nil {
+		log.Debug("Failed to create new request object ", err)
+		return nil, err
+	}
+	req.URL = u // hack to for %2f
+	req.SetBasicAuth(c.credentials(u.Host))
+	if params != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// checkError returns an APIError if the response isn't successful, keeping the start of the body for context
+func checkError(method string, u *url.URL, rsp *http.Response, err error) error {
+	if err != nil || rsp == nil {
+		return err
+	}
+	if rsp.StatusCode >= 200 && rsp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(rsp.Body, maxErrorBody))
+	rsp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), rsp.Body), rsp.Body}
+	apiErr := &APIError{Method: method, URL: u.Opaque, StatusCode: rsp.StatusCode, Body: string(body)}
+	log.Error(apiErr)
+	return apiErr
+}
+
+func (c *Client) sendOnce(ctx context.Context, policy *RetryPolicy, u *url.URL, method string, params interface{}) (*http.Response, error) {
+	req, err := c.createRequest(u, method, params)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, policy.Timeout)
+	release, err := c.limiter.acquire(ctx, u.Host)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	rsp, err := c.HttpClient.Do(req.WithContext(ctx))
+	release()
+	err = checkError(method, u, rsp, err)
+	if rsp != nil {
+		rsp.Body = &cancelOnClose{rsp.Body, cancel}
+	} else {
+		cancel()
+	}
+	return rsp, err
+}
+
+// do sends the request to the host, or another node of its cluster if it's failing, and unmarshals the response
+// into res, if given
+func (c *Client) do(ctx context.Context, op string, host string, method string, path string, params interface{}, res interface{}) (err error) {
+	start := time.Now()
+	defer func() { c.observe(op, host, start, err) }()
+
+	rsp, err := c.sendWithRetries(ctx, op, host, c.nodesFor(host), path, method, params)
+	if rsp != nil {
+		defer rsp.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		return nil
+	}
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		log.Debugf("Error reading response, %+v", err)
+		return err
+	}
+	if err := json.Unmarshal(body, res); err != nil {
+		log.Error("Error unmarshalling ", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package rabbit
+
+import (
+	"context"
+	"flag"
+	"sync"
+)
+
+var hostConcurrency = flag.Int("binding_host_concurrency", 4, "Maximum number of concurrent management API requests per RabbitMQ host")
+
+// hostLimiter bounds the number of in flight requests to each RabbitMQ host
+type hostLimiter struct {
+	sync.Mutex
+	sems map[string]chan struct{}
+}
+
+func newHostLimiter() *hostLimiter {
+	return &hostLimiter{sems: make(map[string]chan struct{})}
+}
+
+// acquire blocks until a slot is available for the host and returns the func to release it.
+// Gives up with the context's error if the context is done first.
+func (l *hostLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	l.Lock()
+	sem, ok := l.sems[host]
+	if !ok {
+		limit := *hostConcurrency
+		if limit < 1 {
+			limit = 1
+		}
+		sem = make(chan struct{}, limit)
+		l.sems[host] = sem
+	}
+	l.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
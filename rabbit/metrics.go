@@ -0,0 +1,52 @@
+package rabbit
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/HailoOSS/binding-service/metrics"
+)
+
+var (
+	requestsTotal   = metrics.NewCounterVec("binding_rabbit_requests_total", "Management API calls by cluster, operation and result", "cluster", "op", "result")
+	requestDuration = metrics.NewHistogramVec("binding_rabbit_request_duration_seconds", "Duration of management API calls including retries", metrics.DefaultBuckets, "cluster", "op")
+	nodeFailovers   = metrics.NewCounterVec("binding_rabbit_node_failovers_total", "Management API calls retried on another node of the cluster", "cluster")
+)
+
+func (c *Client) clusterName(host string) string {
+	if c.ClusterName != nil {
+		if name := c.ClusterName(host); name != "" {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// observe records the outcome of a call
+func (c *Client) observe(op string, host string, start time.Time, err error) {
+	cluster := c.clusterName(host)
+	requestDuration.ObserveSince(start, cluster, op)
+	requestsTotal.Inc(cluster, op, resultLabel(err))
+}
+
+// resultLabel is ok, the status code of an unsuccessful response, timeout, cancelled or error
+func resultLabel(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	if apiErr, ok := err.(*APIError); ok {
+		return strconv.Itoa(apiErr.StatusCode)
+	}
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	switch err {
+	case context.DeadlineExceeded:
+		return "timeout"
+	case context.Canceled:
+		return "cancelled"
+	}
+	return "error"
+}
@@ -1,87 +1,37 @@
 package rabbit
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-)
-
-var RabbitPort string = "15672"
-var RabbitUser string = "hailo"
-var RabbitPass string = "hailo"
+	"net/url"
 
-/*
-[{
-	message_stats: {
-		publish_in: 5,
-		publish_in_details: {
-			rate: 0
-		},
-		publish_out: 5,
-		publish_out_details: {
-			rate: 0
-		}
-	},
-	name: "",
-	vhost: "/",
-	type: "direct",
-	durable: true,
-	auto_delete: false,
-	internal: false,
-	arguments: {
-		alternate-exchange: "h2o.deadletter"
-	},
-	policy: "federate-direct"
-}]
-*/
-
-/*
-type Response struct {
-	Data struct {
-		Children []struct {
-			Data Item
-		}
-	}
-}
-*/
-type Exchange struct {
-	Message_stats struct {
-		Publish_in         interface{}
-		Publish_in_details struct {
-			Rate interface{}
-		}
-		Publish_out         interface{}
-		Publish_out_details struct {
-			Rate interface{}
-		}
-	}
-	Name        string
-	Vhost       string
-	Type        string
-	Durable     bool
-	Auto_delete bool
-	Internal    bool
-	Arguments   map[string]interface{}
-	Policy      string
-}
-
-type Exchanges []*Exchange
+	"github.com/HailoOSS/binding-service/domain"
+)
 
-func GetExchanges(rabbitHost string) ([]*Exchange, error) {
-	rsp, err := callRabbit(rabbitHost, "exchanges", "GET", nil)
-	if err != nil {
-		return nil, err
-	}
-	exch := &Exchanges{}
-	err = json.Unmarshal(rsp, exch)
-	if err != nil {
-		return nil, err
-	}
+// rabbitmq urls
+const (
+	BINDINGS_URL              = "bindings"
+	BINDING_URL               = "bindings/%s/e/%s/%s/%s"
+	DEL_BINDING_URL           = "bindings/%s/e/%s/%s/%s/%s"
+	FED_UPSTREAMS_URL         = "parameters/federation-upstream/%s"
+	FED_UPSTREAM_URL          = "parameters/federation-upstream/%s/%s"
+	POLICIES_LIST_URL         = "policies/%s"
+	POLICIES_URL              = "policies/%s/%s"
+	EXCHANGE_URL              = "exchanges/%s/%s"
+	BINDINGS_FOR_EXCHANGE_URL = "exchanges/%s/%s/bindings/source"
+	EXCHANGES_URL             = "exchanges/%s"
+	QUEUES_URL                = "queues"
+	QUEUE_URL                 = "queues/%s/%s"
+	QUEUE_BINDINGS_URL        = "queues/%s/%s/bindings"
+	QUEUE_CONTENTS_URL        = "queues/%s/%s/contents"
+	QUEUE_GET_URL             = "queues/%s/%s/get"
+	NODES_URL                 = "nodes"
+	FEDERATION_LINKS_URL      = "federation-links/%s"
+	EXCHANGE_PUBLISH_URL      = "exchanges/%s/%s/publish"
+)
 
-	return []*Exchange(*exch), err
-}
+// maximum size of message payloads returned by GetMessages
+const maxPayload = 50000
 
 type Queue struct {
 	Memory        int
@@ -142,128 +92,287 @@ type Queue struct {
 	Node        string
 }
 
-type Queues []*Queue
-
-func GetQueues(rabbitHost string) ([]*Queue, error) {
-	rsp, err := callRabbit(rabbitHost, "queues", "GET", nil)
-	if err != nil {
-		return nil, err
-	}
-	exch := &Queues{}
-	err = json.Unmarshal(rsp, exch)
-	if err != nil {
-		return nil, err
+type Message struct {
+	Payload_bytes int
+	Redelivered   bool
+	Exchange      string
+	Routing_key   string
+	Message_count int
+	Properties    struct {
+		Message_id     string
+		Correlation_id string
+		Reply_to       string
+		Delivery_mode  int
+		Headers        map[string]interface{} // dead-lettered messages have a list of x-death tables
+		Content_type   string
 	}
+	Payload          string
+	Payload_encoding string
+}
 
-	return []*Queue(*exch), err
+// Policy is a rabbit policy, e.g. the ones used to federate exchanges
+type Policy struct {
+	Vhost      string                 `json:"vhost"`
+	Name       string                 `json:"name"`
+	Pattern    string                 `json:"pattern"`
+	ApplyTo    string                 `json:"apply-to,omitempty"`
+	Definition map[string]interface{} `json:"definition"`
+	Priority   int                    `json:"priority"`
 }
 
-type Binding struct {
-	Source           string
-	Vhost            string
-	Destination      string
-	Destination_type string
-	Routing_key      string
-	Arguments        map[string]interface{}
-	Properties_key   string
+// Parameter is a runtime parameter, e.g. a federation upstream
+type Parameter struct {
+	Vhost     string                 `json:"vhost"`
+	Component string                 `json:"component"`
+	Name      string                 `json:"name"`
+	Value     map[string]interface{} `json:"value"`
 }
 
-type Bindings []*Binding
+// Node is a member of a rabbit cluster
+type Node struct {
+	Name       string
+	Type       string
+	Running    bool
+	Partitions []string
+}
 
-func GetBindings(rabbitHost string) ([]*Binding, error) {
-	rsp, err := callRabbit(rabbitHost, "bindings", "GET", nil)
-	if err != nil {
-		return nil, err
+// FederationLink is the status of a federation link, from the federation management plugin
+type FederationLink struct {
+	Node             string `json:"node"`
+	Vhost            string `json:"vhost"`
+	Type             string `json:"type"` // exchange or queue
+	Exchange         string `json:"exchange"`
+	Upstream         string `json:"upstream"`
+	UpstreamExchange string `json:"upstream_exchange"`
+	Status           string `json:"status"` // starting, running, shutdown or error
+	Error            string `json:"error"`
+	Timestamp        string `json:"timestamp"`
+}
+
+// esc escapes a name for use in a url path
+func esc(name string) string {
+	return url.PathEscape(name)
+}
+
+// escVhost escapes a vhost for use in a url path, an empty vhost is the default one
+func escVhost(vhost string) string {
+	if vhost == "" {
+		vhost = domain.DEFAULT_VHOST
 	}
-	exch := &Bindings{}
-	err = json.Unmarshal(rsp, exch)
-	if err != nil {
-		return nil, err
+	return esc(vhost)
+}
+
+/*
+[{
+	message_stats: {
+		publish_in: 5,
+		publish_in_details: {
+			rate: 0
+		},
+		publish_out: 5,
+		publish_out_details: {
+			rate: 0
+		}
+	},
+	name: "",
+	vhost: "/",
+	type: "direct",
+	durable: true,
+	auto_delete: false,
+	internal: false,
+	arguments: {
+		alternate-exchange: "h2o.deadletter"
+	},
+	policy: "federate-direct"
+}]
+*/
+
+// GetExchanges returns the exchanges in the vhost
+func (c *Client) GetExchanges(ctx context.Context, host string, vhost string) ([]*domain.ExchangeDef, error) {
+	var res []*domain.ExchangeDef
+	err := c.do(ctx, OP_GET_EXCHANGES, host, "GET", fmt.Sprintf(EXCHANGES_URL, escVhost(vhost)), nil, &res)
+	return res, err
+}
+
+// PutExchange creates the exchange, or does nothing if it already exists with the same definition
+func (c *Client) PutExchange(ctx context.Context, host string, e *domain.ExchangeDef) error {
+	params := map[string]interface{}{"type": e.Type, "durable": e.Durable, "auto_delete": e.AutoDelete, "internal": e.Internal}
+	if e.Arguments != nil {
+		params["arguments"] = e.Arguments
 	}
+	return c.do(ctx, OP_CREATE_EXCHANGE, host, "PUT", fmt.Sprintf(EXCHANGE_URL, escVhost(e.Vhost), esc(e.Name)), params, nil)
+}
 
-	return []*Binding(*exch), err
+// GetQueues returns the queues in every vhost on the host
+func (c *Client) GetQueues(ctx context.Context, host string) ([]*Queue, error) {
+	var res []*Queue
+	err := c.do(ctx, OP_GET_QUEUES, host, "GET", QUEUES_URL, nil, &res)
+	return res, err
 }
 
-func GetQueue(rabbitHost, queue string) (*Queue, error) {
-	rsp, err := callRabbit(rabbitHost, `queues/%2F/`+queue, "GET", nil)
+func (c *Client) GetQueue(ctx context.Context, host string, vhost string, queue string) (*Queue, error) {
+	res := &Queue{}
+	err := c.do(ctx, OP_GET_QUEUES, host, "GET", fmt.Sprintf(QUEUE_URL, escVhost(vhost), esc(queue)), nil, res)
 	if err != nil {
 		return nil, err
 	}
-	exch := &Queue{}
-	err = json.Unmarshal(rsp, exch)
-	if err != nil {
-		fmt.Println(string(rsp))
-		return nil, err
+	return res, nil
+}
+
+// PutQueue creates a durable queue, or does nothing if it already exists with the same arguments
+func (c *Client) PutQueue(ctx context.Context, host string, vhost string, queue string, args map[string]interface{}) error {
+	params := map[string]interface{}{"durable": true}
+	if args != nil {
+		params["arguments"] = args
 	}
+	return c.do(ctx, OP_CREATE_QUEUE, host, "PUT", fmt.Sprintf(QUEUE_URL, escVhost(vhost), esc(queue)), params, nil)
+}
 
-	return exch, err
+func (c *Client) DeleteQueue(ctx context.Context, host string, vhost string, queue string) error {
+	return c.do(ctx, OP_DELETE_QUEUE, host, "DELETE", fmt.Sprintf(QUEUE_URL, escVhost(vhost), esc(queue)), nil, nil)
 }
 
-type Message struct {
-	Payload_bytes int
-	Redelivered   bool
-	Exchange      string
-	Routing_key   string
-	Message_count int
-	Properties    struct {
-		Message_id    string
-		Reply_to      string
-		Delivery_mode int
-		Headers       map[string]string
-		Content_type  string
-	}
-	Payload          string
-	Payload_encoding string
+// PurgeQueue deletes all the messages in the queue
+func (c *Client) PurgeQueue(ctx context.Context, host string, vhost string, queue string) error {
+	return c.do(ctx, OP_PURGE_QUEUE, host, "DELETE", fmt.Sprintf(QUEUE_CONTENTS_URL, escVhost(vhost), esc(queue)), nil, nil)
 }
 
-type Messages []*Message
+// GetMessages returns up to count messages from the head of the queue, requeueing them
+func (c *Client) GetMessages(ctx context.Context, host string, vhost string, queue string, count int) ([]*Message, error) {
+	params := map[string]interface{}{"count": count, "requeue": true, "encoding": "auto", "truncate": maxPayload}
+	var res []*Message
+	err := c.do(ctx, OP_GET_MESSAGES, host, "POST", fmt.Sprintf(QUEUE_GET_URL, escVhost(vhost), esc(queue)), params, &res)
+	return res, err
+}
 
-func DeleteQueueMessages(rabbitHost, queue string) error {
-	_, err := callRabbit(rabbitHost, `queues/%2F/`+queue+`/contents`, "DELETE", nil)
-	if err != nil {
-		fmt.Println("error deleting")
-		return err
+// TakeMessages removes up to count messages from the head of the queue and returns them in full
+func (c *Client) TakeMessages(ctx context.Context, host string, vhost string, queue string, count int) ([]*Message, error) {
+	params := map[string]interface{}{"count": count, "requeue": false, "encoding": "auto"}
+	var res []*Message
+	err := c.do(ctx, OP_TAKE_MESSAGES, host, "POST", fmt.Sprintf(QUEUE_GET_URL, escVhost(vhost), esc(queue)), params, &res)
+	return res, err
+}
+
+// Publish publishes a message taken from a queue to the exchange, with its routing key and properties. It returns
+// whether it was routed to a queue.
+func (c *Client) Publish(ctx context.Context, host string, vhost string, exchange string, m *Message) (bool, error) {
+	properties := map[string]interface{}{}
+	if m.Properties.Message_id != "" {
+		properties["message_id"] = m.Properties.Message_id
+	}
+	if m.Properties.Correlation_id != "" {
+		properties["correlation_id"] = m.Properties.Correlation_id
+	}
+	if m.Properties.Reply_to != "" {
+		properties["reply_to"] = m.Properties.Reply_to
+	}
+	if m.Properties.Delivery_mode != 0 {
+		properties["delivery_mode"] = m.Properties.Delivery_mode
+	}
+	if m.Properties.Content_type != "" {
+		properties["content_type"] = m.Properties.Content_type
+	}
+	if len(m.Properties.Headers) > 0 {
+		properties["headers"] = m.Properties.Headers
 	}
-	return nil
+	encoding := m.Payload_encoding
+	if encoding == "" {
+		encoding = "string"
+	}
+	params := map[string]interface{}{"properties": properties, "routing_key": m.Routing_key, "payload": m.Payload, "payload_encoding": encoding}
+	var res struct {
+		Routed bool `json:"routed"`
+	}
+	err := c.do(ctx, OP_PUBLISH, host, "POST", fmt.Sprintf(EXCHANGE_PUBLISH_URL, escVhost(vhost), esc(exchange)), params, &res)
+	return res.Routed, err
 }
 
-func GetExampleMessages(rabbitHost, queue string) ([]*Message, error) {
+// GetBindings returns every binding in every vhost on the host
+func (c *Client) GetBindings(ctx context.Context, host string) ([]*domain.BindingDef, error) {
+	var res []*domain.BindingDef
+	err := c.do(ctx, OP_GET_BINDINGS, host, "GET", BINDINGS_URL, nil, &res)
+	return res, err
+}
 
-	jsonStr := `{"count":5,"requeue":true,"encoding":"auto","truncate":50000}`
-	rsp, err := callRabbit(rabbitHost, `queues/%2F/`+queue+`/get`, "POST", []byte(jsonStr))
-	if err != nil {
-		fmt.Println("error posting")
-		return nil, err
-	}
+// GetBindingsBetween returns the bindings from the exchange to the queue or exchange
+func (c *Client) GetBindingsBetween(ctx context.Context, host string, vhost string, fromExchange string, toType domain.DestinationTypeS, to string) ([]*domain.BindingDef, error) {
+	// GET Request URL:http://protobroker03-global01-test.i.HailoOSS.com:15672/api/bindings/%2f/e/h2o/e/eu-west-1a/
+	var res []*domain.BindingDef
+	err := c.do(ctx, OP_GET_BINDINGS, host, "GET", fmt.Sprintf(BINDING_URL, escVhost(vhost), esc(fromExchange), string(toType), esc(to)), nil, &res)
+	return res, err
+}
 
-	exch := &Messages{}
+// GetBindingsForSource returns the bindings from the exchange
+func (c *Client) GetBindingsForSource(ctx context.Context, host string, vhost string, exchange string) ([]*domain.BindingDef, error) {
+	// GET Request URL:http://protobroker03-global01-test.i.HailoOSS.com:15672/api/exchanges/%2f/h2o/bindings/source
+	var res []*domain.BindingDef
+	err := c.do(ctx, OP_GET_BINDINGS, host, "GET", fmt.Sprintf(BINDINGS_FOR_EXCHANGE_URL, escVhost(vhost), esc(exchange)), nil, &res)
+	return res, err
+}
 
-	err = json.Unmarshal(rsp, exch)
-	if err != nil {
-		fmt.Println(string(rsp))
-		return nil, err
-	}
+// GetQueueBindings returns the bindings to the queue from any exchange
+func (c *Client) GetQueueBindings(ctx context.Context, host string, vhost string, queue string) ([]*domain.BindingDef, error) {
+	var res []*domain.BindingDef
+	err := c.do(ctx, OP_GET_BINDINGS, host, "GET", fmt.Sprintf(QUEUE_BINDINGS_URL, escVhost(vhost), esc(queue)), nil, &res)
+	return res, err
+}
 
-	return []*Message(*exch), err
+func (c *Client) CreateBinding(ctx context.Context, host string, b *domain.BindingDef) error {
+	return c.do(ctx, OP_CREATE_BINDING, host, "POST", fmt.Sprintf(BINDING_URL, escVhost(b.Vhost), esc(b.Source), b.GetDestTypeCode(), esc(b.Destination)), b, nil)
 }
 
-func callRabbit(rabbitHost, path, method string, b []byte) ([]byte, error) {
+func (c *Client) DeleteBinding(ctx context.Context, host string, b *domain.BindingDef) error {
+	// DELETE Request URL:http://protobroker01-global01-test.i.HailoOSS.com:15672/api/bindings/%2F/e/h2o/e/eu-west-1c/~_FUDj6QombDT58zwoCtUyA
+	// {"vhost":"/","source":"h2o","destination":"eu-west-1c","destination_type":"e","properties_key":"~_FUDj6QombDT58zwoCtUyA"}
+	return c.do(ctx, OP_DELETE_BINDING, host, "DELETE", fmt.Sprintf(DEL_BINDING_URL, escVhost(b.Vhost), esc(b.Source), b.GetDestTypeCode(), esc(b.Destination), esc(b.PropertiesKey)), nil, nil)
+}
 
-	req, err := http.NewRequest(method, fmt.Sprintf("%s:%s/api/%s", rabbitHost, RabbitPort, path), bytes.NewReader(b))
+func (c *Client) GetFederationUpstreams(ctx context.Context, host string, vhost string) ([]*Parameter, error) {
+	var res []*Parameter
+	err := c.do(ctx, OP_GET_UPSTREAMS, host, "GET", fmt.Sprintf(FED_UPSTREAMS_URL, escVhost(vhost)), nil, &res)
+	return res, err
+}
 
-	if err != nil {
-		return nil, err
-	}
-	//have to do this cruft to get away with %2F in the url path
-	req.URL.Opaque = "//" + req.URL.Host + "/api/" + path
+func (c *Client) PutFederationUpstream(ctx context.Context, host string, vhost string, name string, value map[string]interface{}) error {
+	params := map[string]interface{}{"value": value}
+	return c.do(ctx, OP_CREATE_UPSTREAM, host, "PUT", fmt.Sprintf(FED_UPSTREAM_URL, escVhost(vhost), esc(name)), params, nil)
+}
 
-	req.SetBasicAuth(RabbitUser, RabbitPass)
+func (c *Client) DeleteFederationUpstream(ctx context.Context, host string, vhost string, name string) error {
+	return c.do(ctx, OP_DELETE_UPSTREAM, host, "DELETE", fmt.Sprintf(FED_UPSTREAM_URL, escVhost(vhost), esc(name)), nil, nil)
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
+func (c *Client) GetPolicies(ctx context.Context, host string, vhost string) ([]*Policy, error) {
+	var res []*Policy
+	err := c.do(ctx, OP_GET_POLICIES, host, "GET", fmt.Sprintf(POLICIES_LIST_URL, escVhost(vhost)), nil, &res)
+	return res, err
+}
+
+func (c *Client) PutPolicy(ctx context.Context, host string, p *Policy) error {
+	params := map[string]interface{}{"pattern": p.Pattern, "definition": p.Definition}
+	if p.ApplyTo != "" {
+		params["apply-to"] = p.ApplyTo
 	}
-	rb, err := ioutil.ReadAll(resp.Body)
-	resp.Body.Close()
-	return rb, err
+	if p.Priority != 0 {
+		params["priority"] = p.Priority
+	}
+	return c.do(ctx, OP_CREATE_POLICY, host, "PUT", fmt.Sprintf(POLICIES_URL, escVhost(p.Vhost), esc(p.Name)), params, nil)
+}
+
+func (c *Client) DeletePolicy(ctx context.Context, host string, vhost string, name string) error {
+	return c.do(ctx, OP_DELETE_POLICY, host, "DELETE", fmt.Sprintf(POLICIES_URL, escVhost(vhost), esc(name)), nil, nil)
+}
+
+// GetNodes returns the members of the host's cluster
+func (c *Client) GetNodes(ctx context.Context, host string) ([]*Node, error) {
+	var res []*Node
+	err := c.do(ctx, OP_GET_NODES, host, "GET", NODES_URL, nil, &res)
+	return res, err
+}
+
+// GetFederationLinks returns the federation links of every node in the host's cluster in the vhost
+func (c *Client) GetFederationLinks(ctx context.Context, host string, vhost string) ([]*FederationLink, error) {
+	var res []*FederationLink
+	err := c.do(ctx, OP_GET_LINKS, host, "GET", fmt.Sprintf(FEDERATION_LINKS_URL, escVhost(vhost)), nil, &res)
+	return res, err
 }
@@ -0,0 +1,229 @@
+package rabbit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+)
+
+// management API operations, each can have its own retry policy
+const (
+	OP_CREATE_BINDING  = "create_binding"
+	OP_DELETE_BINDING  = "delete_binding"
+	OP_GET_BINDINGS    = "get_bindings"
+	OP_GET_EXCHANGES   = "get_exchanges"
+	OP_CREATE_EXCHANGE = "create_exchange"
+	OP_GET_QUEUES      = "get_queues"
+	OP_CREATE_QUEUE    = "create_queue"
+	OP_DELETE_QUEUE    = "delete_queue"
+	OP_PURGE_QUEUE     = "purge_queue"
+	OP_GET_MESSAGES    = "get_messages"
+	OP_TAKE_MESSAGES   = "take_messages"
+	OP_PUBLISH         = "publish"
+	OP_GET_UPSTREAMS   = "get_upstreams"
+	OP_CREATE_UPSTREAM = "create_upstream"
+	OP_DELETE_UPSTREAM = "delete_upstream"
+	OP_GET_POLICIES    = "get_policies"
+	OP_CREATE_POLICY   = "create_policy"
+	OP_DELETE_POLICY   = "delete_policy"
+	OP_GET_NODES       = "get_nodes"
+	OP_GET_LINKS       = "get_links"
+)
+
+var (
+	requestAttempts   = flag.Int("binding_retry_attempts", 3, "Maximum attempts for a management API call")
+	requestTimeout    = flag.Duration("binding_retry_timeout", 10*time.Second, "Timeout for each attempt of a management API call")
+	requestBackoff    = flag.Duration("binding_retry_backoff", 250*time.Millisecond, "Initial delay between attempts of a management API call, doubles with each attempt")
+	requestMaxBackoff = flag.Duration("binding_retry_max_backoff", 5*time.Second, "Maximum delay between attempts of a management API call")
+	requestBudget     = flag.Duration("binding_retry_budget", 30*time.Second, "Maximum total time for all attempts of a management API call")
+	retryPolicies     = flag.String("binding_retry_policies", "", `Per operation overrides as json, e.g. {"delete_binding":{"attempts":5,"timeout":"5s"}}`)
+
+	policiesOnce sync.Once
+	policies     map[string]*RetryPolicy
+)
+
+// RetryPolicy controls how a management API call is retried on network errors and 5xx responses
+type RetryPolicy struct {
+	Attempts   int
+	Timeout    time.Duration // per attempt
+	Backoff    time.Duration // initial delay between attempts
+	MaxBackoff time.Duration
+	Budget     time.Duration // across all attempts
+	Idempotent bool          // whether non idempotent methods (POST) can be retried for this operation
+}
+
+type retryPolicyConfig struct {
+	Attempts   *int    `json:"attempts"`
+	Timeout    *string `json:"timeout"`
+	Backoff    *string `json:"backoff"`
+	MaxBackoff *string `json:"maxBackoff"`
+	Budget     *string `json:"budget"`
+}
+
+func defaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{Attempts: *requestAttempts, Timeout: *requestTimeout, Backoff: *requestBackoff, MaxBackoff: *requestMaxBackoff, Budget: *requestBudget}
+}
+
+// getRetryPolicy returns the policy for the operation, loading the overrides the first time it's called
+func getRetryPolicy(op string) *RetryPolicy {
+	policiesOnce.Do(loadRetryPolicies)
+	if p, ok := policies[op]; ok {
+		return p
+	}
+	return defaultRetryPolicy()
+}
+
+func loadRetryPolicies() {
+	policies = make(map[string]*RetryPolicy)
+	// rabbit ignores a binding that already exists so creating one is safe to retry
+	createBinding := defaultRetryPolicy()
+	createBinding.Idempotent = true
+	policies[OP_CREATE_BINDING] = createBinding
+	// getting messages requeues them, so is safe to retry
+	getMessages := defaultRetryPolicy()
+	getMessages.Idempotent = true
+	policies[OP_GET_MESSAGES] = getMessages
+
+	if *retryPolicies == "" {
+		return
+	}
+	var configs map[string]*retryPolicyConfig
+	if err := json.Unmarshal([]byte(*retryPolicies), &configs); err != nil {
+		log.Errorf("Error parsing retry policies, using defaults %+v", err)
+		return
+	}
+	for op, c := range configs {
+		p, ok := policies[op]
+		if !ok {
+			p = defaultRetryPolicy()
+		}
+		if c.Attempts != nil {
+			p.Attempts = *c.Attempts
+		}
+		setDuration(&p.Timeout, c.Timeout, op)
+		setDuration(&p.Backoff, c.Backoff, op)
+		setDuration(&p.MaxBackoff, c.MaxBackoff, op)
+		setDuration(&p.Budget, c.Budget, op)
+		policies[op] = p
+	}
+}
+
+func setDuration(d *time.Duration, s *string, op string) {
+	if s == nil {
+		return
+	}
+	v, err := time.ParseDuration(*s)
+	if err != nil {
+		log.Errorf("Error parsing retry policy duration %s for %s %+v", *s, op, err)
+		return
+	}
+	*d = v
+}
+
+// isRetryable returns whether a failed attempt should be retried. Failing to connect is retryable for every method as
+// the request was never sent.
+func (p *RetryPolicy) isRetryable(method string, rsp *http.Response, err error) bool {
+	if isDialError(err) {
+		return true
+	}
+	if method == "POST" && !p.Idempotent {
+		return false
+	}
+	if rsp != nil {
+		return rsp.StatusCode >= 500
+	}
+	// no response so it's a network error
+	return err != nil
+}
+
+// isDialError returns whether the error is from connecting to the node, e.g. connection refused
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return addJitterTo(d / 2)
+}
+
+func addJitterTo(d time.Duration) time.Duration {
+	return time.Duration(rand.Float64()*float64(d)) + d
+}
+
+// cancelOnClose releases a request's context once its response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// sendWithRetries sends the request to the nodes, in order, according to the operation's retry policy. A node
+// which fails is failed over from straight away, and a node is only retried, after backing off, once every node has
+// been tried. One budget covers every attempt on every node so a dead node can't use it all up.
+// Must close the response body when finished with it.
+func (c *Client) sendWithRetries(ctx context.Context, op string, host string, nodes []string, path string, method string, params interface{}) (*http.Response, error) {
+	policy := getRetryPolicy(op)
+	budget, cancel := context.WithTimeout(ctx, policy.Budget)
+	retries := 0
+	for i := 0; ; i++ {
+		node := nodes[i%len(nodes)]
+		u := c.makeURL(node, path)
+		log.Debugf("Sending request to url %s params %s", u.Opaque, redact(params))
+		rsp, err := c.sendOnce(budget, policy, u, method, params)
+		if ctx.Err() == nil {
+			// the caller giving up says nothing about the node
+			if nodeFailed(rsp, err) {
+				c.Nodes.Failure(node, err)
+			} else {
+				c.Nodes.Success(node)
+			}
+		}
+		failover := i+1 < len(nodes)
+		if err == nil || budget.Err() != nil || !policy.isRetryable(method, rsp, err) || (!failover && retries+1 >= policy.Attempts) {
+			if rsp != nil {
+				rsp.Body = &cancelOnClose{rsp.Body, cancel}
+			} else {
+				cancel()
+			}
+			return rsp, err
+		}
+		if rsp != nil {
+			rsp.Body.Close()
+		}
+		if failover {
+			log.Warnf("Call to rabbit node %s failed, trying %s: %v", node, nodes[i+1], err)
+			nodeFailovers.Inc(c.clusterName(host))
+			continue
+		}
+		retries++
+		backoff := policy.backoff(retries)
+		log.Debugf("Attempt %d of %s %s failed, retrying in %v: %v", i+1, method, op, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-budget.Done():
+			cancel()
+			return nil, err
+		}
+	}
+}
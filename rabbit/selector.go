@@ -0,0 +1,122 @@
+package rabbit
+
+import (
+	"flag"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	nodeFailureThreshold = flag.Int("binding_node_failure_threshold", 2, "Consecutive failed calls after which a rabbit node is treated as unhealthy")
+	nodeCooldown         = flag.Duration("binding_node_cooldown", 30*time.Second, "How long an unhealthy rabbit node is avoided before it's tried again")
+)
+
+// NodeHealth is what's known about the health of a rabbit node's management API
+type NodeHealth struct {
+	Host        string
+	Healthy     bool
+	Failures    int // consecutive failed calls
+	LastFailure time.Time
+	LastSuccess time.Time
+	LastError   string
+}
+
+// healthy returns whether the node should be used, unhealthy nodes are tried again once the cooldown has passed
+func (n *NodeHealth) healthy(now time.Time) bool {
+	return n.Failures < *nodeFailureThreshold || now.Sub(n.LastFailure) > *nodeCooldown
+}
+
+// NodeSelector tracks the outcome of calls to each node so that calls to a cluster go to its healthy nodes first
+type NodeSelector struct {
+	sync.Mutex
+	nodes map[string]*NodeHealth
+}
+
+func NewNodeSelector() *NodeSelector {
+	return &NodeSelector{nodes: make(map[string]*NodeHealth)}
+}
+
+func (s *NodeSelector) node(host string) *NodeHealth {
+	n, ok := s.nodes[host]
+	if !ok {
+		n = &NodeHealth{Host: host}
+		s.nodes[host] = n
+	}
+	return n
+}
+
+// Success records a successful call to the node
+func (s *NodeSelector) Success(host string) {
+	s.Lock()
+	defer s.Unlock()
+	n := s.node(host)
+	n.Failures = 0
+	n.LastSuccess = time.Now()
+}
+
+// Failure records a failed call to the node
+func (s *NodeSelector) Failure(host string, err error) {
+	s.Lock()
+	defer s.Unlock()
+	n := s.node(host)
+	n.Failures++
+	n.LastFailure = time.Now()
+	if err != nil {
+		n.LastError = err.Error()
+	}
+}
+
+// Order returns the nodes in the order they should be tried. Healthy nodes come first, with preferred first if it's
+// one of them and the rest shuffled to spread the load. Unhealthy nodes follow, the ones which failed longest ago
+// first.
+func (s *NodeSelector) Order(preferred string, hosts []string) []string {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	healthy := make([]string, 0, len(hosts))
+	unhealthy := make([]*NodeHealth, 0)
+	for _, i := range rand.Perm(len(hosts)) {
+		h := hosts[i]
+		if n, ok := s.nodes[h]; ok && !n.healthy(now) {
+			unhealthy = append(unhealthy, n)
+			continue
+		}
+		if h == preferred {
+			healthy = append([]string{h}, healthy...)
+		} else {
+			healthy = append(healthy, h)
+		}
+	}
+	sort.Slice(unhealthy, func(i, j int) bool { return unhealthy[i].LastFailure.Before(unhealthy[j].LastFailure) })
+	for _, n := range unhealthy {
+		healthy = append(healthy, n.Host)
+	}
+	return healthy
+}
+
+// Pick returns the node which should be used for calls to the cluster
+func (s *NodeSelector) Pick(hosts []string) string {
+	if len(hosts) == 0 {
+		return ""
+	}
+	return s.Order("", hosts)[0]
+}
+
+// Health returns the health of the hosts, nodes which haven't been called yet are healthy
+func (s *NodeSelector) Health(hosts []string) []*NodeHealth {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	res := make([]*NodeHealth, 0, len(hosts))
+	for _, h := range hosts {
+		n := NodeHealth{Host: h}
+		if known, ok := s.nodes[h]; ok {
+			n = *known
+		}
+		n.Healthy = n.healthy(now)
+		res = append(res, &n)
+	}
+	return res
+}
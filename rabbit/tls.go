@@ -0,0 +1,40 @@
+package rabbit
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+)
+
+var (
+	apiCAFile   = flag.String("rabbit_api_ca_file", "", "PEM bundle of CAs to trust for the https management API, defaults to the system roots")
+	apiCertFile = flag.String("rabbit_api_cert_file", "", "PEM client certificate for the https management API")
+	apiKeyFile  = flag.String("rabbit_api_key_file", "", "PEM key of the client certificate for the https management API")
+)
+
+// NewTLSConfig returns a tls config trusting the CAs in caFile, if given, and presenting the client certificate,
+// if given
+func NewTLSConfig(caFile string, certFile string, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading CA bundle %s: %v", caFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in CA bundle %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading client certificate %s: %v", certFile, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,50 @@
+package util
+
+import (
+	"flag"
+	"reflect"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+)
+
+var (
+	discoveredNodesTTL = flag.Duration("rabbit_discovered_nodes_ttl", 5*time.Minute, "How long discovered cluster nodes are used for before falling back to the rabbit hosts file")
+
+	discovered = &discoveredCache{byAz: make(map[string]*discoveredNodes)}
+)
+
+type discoveredNodes struct {
+	nodes []string
+	at    time.Time
+}
+
+// discoveredCache holds the nodes of each cluster discovered from rabbit
+type discoveredCache struct {
+	sync.RWMutex
+	byAz map[string]*discoveredNodes
+}
+
+// SetDiscoveredNodes records the nodes of the cluster in azName, they're used instead of the configured nodes until
+// they expire
+func SetDiscoveredNodes(azName string, nodes []string) {
+	discovered.Lock()
+	defer discovered.Unlock()
+	prev, ok := discovered.byAz[azName]
+	if !ok || !reflect.DeepEqual(prev.nodes, nodes) {
+		log.Infof("Discovered nodes of cluster %s changed to %v", azName, nodes)
+	}
+	discovered.byAz[azName] = &discoveredNodes{nodes: nodes, at: time.Now()}
+}
+
+// getDiscoveredNodes returns the discovered nodes of the cluster in azName, or nil if there aren't any recent ones
+func getDiscoveredNodes(azName string) []string {
+	discovered.RLock()
+	defer discovered.RUnlock()
+	d, ok := discovered.byAz[azName]
+	if !ok || len(d.nodes) == 0 || time.Since(d.at) > *discoveredNodesTTL {
+		return nil
+	}
+	return d.nodes
+}
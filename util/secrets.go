@@ -0,0 +1,104 @@
+package util
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+)
+
+var (
+	rabbitSecretsFile = flag.String("rabbit_secrets_file", "/etc/h2o/rabbitsecrets", "Json file of management API credentials keyed by host, AZ name or \"default\"")
+
+	secrets = &secretsCache{}
+)
+
+// RabbitCredentials are the credentials for a rabbit cluster
+type RabbitCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// String never includes the password so credentials can't leak into logs
+func (c *RabbitCredentials) String() string {
+	return c.Username + ":xxxxx"
+}
+
+// secretsCache holds the contents of the secrets file. It's loaded the first time it's needed and reloaded with the
+// rabbit hosts, see WatchRabbitHosts.
+type secretsCache struct {
+	sync.RWMutex
+	loaded  bool
+	modTime time.Time // of the file last read, whether or not it was valid
+	creds   map[string]*RabbitCredentials
+}
+
+func (s *secretsCache) get() map[string]*RabbitCredentials {
+	s.RLock()
+	loaded, creds := s.loaded, s.creds
+	s.RUnlock()
+	if loaded {
+		return creds
+	}
+	s.reload(false)
+	s.RLock()
+	defer s.RUnlock()
+	return s.creds
+}
+
+// reload reads the secrets file if it's changed since it was last read, or regardless if force is set. If it can't be
+// read or parsed the error is logged, once for each change to the file, and the last good credentials are kept.
+func (s *secretsCache) reload(force bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.loaded = true
+	fi, err := os.Stat(*rabbitSecretsFile)
+	if err != nil {
+		// no secrets file, fall back to the default credentials
+		if s.creds != nil {
+			log.Infof("Rabbit secrets file %s has gone, using the default credentials", *rabbitSecretsFile)
+		}
+		s.creds, s.modTime = nil, time.Time{}
+		return
+	}
+	if !force && fi.ModTime().Equal(s.modTime) {
+		return
+	}
+	s.modTime = fi.ModTime()
+	b, err := ioutil.ReadFile(*rabbitSecretsFile)
+	if err != nil {
+		log.Errorf("Couldn't read rabbit secrets file %s, keeping the previous credentials: %v", *rabbitSecretsFile, err)
+		return
+	}
+	creds := make(map[string]*RabbitCredentials)
+	if err := json.Unmarshal(b, &creds); err != nil {
+		log.Errorf("Couldn't parse rabbit secrets file %s, keeping the previous credentials: %v", *rabbitSecretsFile, err)
+		return
+	}
+	s.creds = creds
+	log.Infof("Loaded %d rabbit credentials from %s", len(creds), *rabbitSecretsFile)
+}
+
+// GetRabbitCredentials returns the credentials for the rabbit host. They're looked up in the secrets file by host,
+// then by the AZ of the host's cluster, then from the cluster's config and finally the "default" secrets entry.
+func GetRabbitCredentials(host string) (*RabbitCredentials, bool) {
+	creds := secrets.get()
+	host = stripPort(host)
+	if c, ok := creds[host]; ok {
+		return c, true
+	}
+	if cluster, ok := GetRabbitCluster(host); ok {
+		if c, ok := creds[cluster.AzName]; ok {
+			return c, true
+		}
+		if cluster.Username != "" {
+			return &RabbitCredentials{Username: cluster.Username, Password: cluster.Password}, true
+		}
+	}
+	c, ok := creds["default"]
+	return c, ok
+}
@@ -1,39 +1,394 @@
 package util
 
 import (
+	"bytes"
 	"encoding/csv"
-	log "github.com/cihub/seelog"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
 	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/cihub/seelog"
 )
 
-// Returns map of azName to rabbit hosts
-func GetRabbitHosts() (map[string][]string, error) {
+const (
+	ROLE_PRIMARY = "primary"
+	ROLE_STANDBY = "standby"
+)
 
-	file, err := os.Open("/etc/h2o/rabbithosts")
-	if err != nil {
-		log.Criticalf("Couldn't read file /etc/h2o/rabbithosts This file should contain the rabbit clusters: %v", err)
-		return nil, err
+var (
+	rabbitHostsFile = flag.String("rabbit_hosts_file", "/etc/h2o/rabbithosts", "File describing the rabbit clusters, either json or host,azname csv")
+	rabbitHostsPoll = flag.Duration("rabbit_hosts_poll", 10*time.Second, "How often to check the rabbit hosts file for changes, 0 to only reload on SIGHUP")
+
+	clusters    = &clusterCache{}
+	watchOnce   sync.Once
+	reloadMutex sync.Mutex
+)
+
+// RabbitCluster describes a rabbit cluster, one per AZ
+type RabbitCluster struct {
+	AzName         string   `json:"az"`
+	Nodes          []string `json:"nodes"`
+	ManagementPort int      `json:"management_port,omitempty"` // defaults to the client's port
+	AmqpURI        string   `json:"amqp_uri,omitempty"`        // used for federating from the cluster, defaults to amqp://<node>
+	Username       string   `json:"username,omitempty"`
+	Password       string   `json:"password,omitempty"`
+	Role           string   `json:"role,omitempty"` // primary or standby, defaults to primary
+	Seeds          []string `json:"-"`              // the configured nodes, when Nodes were discovered from them
+}
+
+// String never includes the password so clusters can be logged
+func (c *RabbitCluster) String() string {
+	return fmt.Sprintf("{az:%s nodes:%v management_port:%d amqp_uri:%s role:%s}", c.AzName, c.Nodes, c.ManagementPort, redactURI(c.AmqpURI), c.Role)
+}
+
+// IsStandby returns whether the cluster is a standby, kept ready to fail over to. Nothing is bound to a standby
+// cluster's AZ from the others, and it isn't health checked or monitored, until it's made primary.
+func (c *RabbitCluster) IsStandby() bool {
+	return c.Role == ROLE_STANDBY
+}
+
+// HasNode returns whether the host is one of the cluster's nodes or seeds, ignoring any port
+func (c *RabbitCluster) HasNode(host string) bool {
+	host = stripPort(host)
+	for _, nodes := range [][]string{c.Nodes, c.Seeds} {
+		for _, n := range nodes {
+			if stripPort(n) == host {
+				return true
+			}
+		}
 	}
-	defer file.Close()
-	rdr := csv.NewReader(file)
-	retMap := make(map[string][]string)
-	for {
-		line, err := rdr.Read()
+	return false
+}
+
+// ManagementHosts returns the nodes with the management port, if the cluster has one
+func (c *RabbitCluster) ManagementHosts() []string {
+	res := make([]string, 0, len(c.Nodes))
+	for _, n := range c.Nodes {
+		if c.ManagementPort != 0 && n == stripPort(n) {
+			n = net.JoinHostPort(n, fmt.Sprint(c.ManagementPort))
+		}
+		res = append(res, n)
+	}
+	return res
+}
+
+func (c *RabbitCluster) validate() error {
+	if c.AzName == "" {
+		return fmt.Errorf("Cluster %v has no az", c)
+	}
+	if len(c.Nodes) == 0 {
+		return fmt.Errorf("Cluster %s has no nodes", c.AzName)
+	}
+	for _, n := range c.Nodes {
+		if strings.TrimSpace(n) == "" {
+			return fmt.Errorf("Cluster %s has an empty node", c.AzName)
+		}
+	}
+	if c.ManagementPort < 0 || c.ManagementPort > 65535 {
+		return fmt.Errorf("Cluster %s has invalid management port %d", c.AzName, c.ManagementPort)
+	}
+	if c.AmqpURI != "" {
+		u, err := url.Parse(c.AmqpURI)
+		if err != nil {
+			// the error would include the uri, and so the password
+			return fmt.Errorf("Cluster %s has an amqp uri which can't be parsed", c.AzName)
+		}
+		if u.Scheme != "amqp" && u.Scheme != "amqps" {
+			return fmt.Errorf("Cluster %s has amqp uri with unsupported scheme %s", c.AzName, u.Scheme)
+		}
+	}
+	switch c.Role {
+	case "":
+		c.Role = ROLE_PRIMARY
+	case ROLE_PRIMARY, ROLE_STANDBY:
+	default:
+		return fmt.Errorf("Cluster %s has unknown role %s", c.AzName, c.Role)
+	}
+	return nil
+}
+
+// rabbitHostsConfig is the json format of the rabbit hosts file
+type rabbitHostsConfig struct {
+	Clusters []*RabbitCluster `json:"clusters"`
+}
+
+// clusterCache holds the last valid contents of the rabbit hosts file
+type clusterCache struct {
+	sync.RWMutex
+	modTime  time.Time
+	clusters map[string]*RabbitCluster
+}
+
+func (c *clusterCache) get() map[string]*RabbitCluster {
+	c.RLock()
+	defer c.RUnlock()
+	return c.clusters
+}
+
+// ParseRabbitHosts parses and validates the rabbit hosts file, which is either json or the original host,azname csv
+func ParseRabbitHosts(b []byte) (map[string]*RabbitCluster, error) {
+	trimmed := bytes.TrimSpace(b)
+	var list []*RabbitCluster
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		config := &rabbitHostsConfig{}
+		if err := json.Unmarshal(trimmed, config); err != nil {
+			return nil, fmt.Errorf("Error parsing json: %v", err)
+		}
+		list = config.Clusters
+	} else {
+		var err error
+		if list, err = parseCSV(trimmed); err != nil {
+			return nil, err
+		}
+	}
+	res := make(map[string]*RabbitCluster)
+	for _, c := range list {
+		if c == nil {
+			return nil, fmt.Errorf("Empty cluster")
+		}
+		if err := c.validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := res[c.AzName]; ok {
+			return nil, fmt.Errorf("Cluster %s is defined more than once", c.AzName)
+		}
+		res[c.AzName] = c
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("No clusters defined")
+	}
+	return res, nil
+}
+
+func parseCSV(b []byte) ([]*RabbitCluster, error) {
+	rdr := csv.NewReader(bytes.NewReader(b))
+	rdr.FieldsPerRecord = -1
+	byAz := make(map[string]*RabbitCluster)
+	list := make([]*RabbitCluster, 0)
+	for line := 1; ; line++ {
+		fields, err := rdr.Read()
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			log.Criticalf("Couldn't read file /etc/h2o/rabbithosts This file should contain the rabbit clusters: %v", err)
 			return nil, err
 		}
-		host := line[0]
-		azName := line[1]
-		list, ok := retMap[azName]
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("Line %d should be host,azname but is %q", line, strings.Join(fields, ","))
+		}
+		host, azName := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1])
+		c, ok := byAz[azName]
 		if !ok {
-			list = make([]string, 0)
+			c = &RabbitCluster{AzName: azName}
+			byAz[azName] = c
+			list = append(list, c)
 		}
-		list = append(list, host)
-		retMap[azName] = list
+		c.Nodes = append(c.Nodes, host)
+	}
+	return list, nil
+}
+
+// ReloadRabbitHosts reads the rabbit hosts file, replacing the cached clusters and logging what changed if it's
+// valid. The previous clusters are kept if it isn't.
+func ReloadRabbitHosts() error {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+	return reloadRabbitHosts()
+}
+
+func reloadRabbitHosts() error {
+	fi, err := os.Stat(*rabbitHostsFile)
+	if err != nil {
+		log.Criticalf("Couldn't read file %s This file should contain the rabbit clusters: %v", *rabbitHostsFile, err)
+		return err
+	}
+	b, err := ioutil.ReadFile(*rabbitHostsFile)
+	if err != nil {
+		log.Criticalf("Couldn't read file %s This file should contain the rabbit clusters: %v", *rabbitHostsFile, err)
+		return err
+	}
+	parsed, err := ParseRabbitHosts(b)
+	if err != nil {
+		err = fmt.Errorf("Invalid rabbit hosts file %s: %v", *rabbitHostsFile, err)
+		log.Critical(err)
+		return err
+	}
+
+	clusters.Lock()
+	old := clusters.clusters
+	clusters.clusters = parsed
+	clusters.modTime = fi.ModTime()
+	clusters.Unlock()
+
+	if old == nil {
+		log.Infof("Loaded %d rabbit clusters from %s", len(parsed), *rabbitHostsFile)
+	}
+	for _, change := range diffClusters(old, parsed) {
+		log.Infof("Rabbit hosts changed: %s", change)
+	}
+	return nil
+}
+
+// diffClusters describes the differences between the old and new clusters, without any credentials
+func diffClusters(prev, next map[string]*RabbitCluster) []string {
+	res := make([]string, 0)
+	if prev == nil {
+		return res
+	}
+	for az, n := range next {
+		o, ok := prev[az]
+		switch {
+		case !ok:
+			res = append(res, fmt.Sprintf("added %v", n))
+		case o.Username != n.Username || o.Password != n.Password:
+			res = append(res, fmt.Sprintf("credentials changed for %s", az))
+			fallthrough
+		default:
+			oc, nc := *o, *n
+			oc.Username, oc.Password, nc.Username, nc.Password = "", "", "", ""
+			if !reflect.DeepEqual(oc, nc) {
+				res = append(res, fmt.Sprintf("changed %v to %v", o, n))
+			}
+		}
+	}
+	for az, o := range prev {
+		if _, ok := next[az]; !ok {
+			res = append(res, fmt.Sprintf("removed %v", o))
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// WatchRabbitHosts reloads the rabbit hosts and secrets files on SIGHUP and whenever they change
+func WatchRabbitHosts() {
+	watchOnce.Do(func() {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		var tick <-chan time.Time
+		if *rabbitHostsPoll > 0 {
+			tick = time.NewTicker(*rabbitHostsPoll).C
+		}
+		go func() {
+			for {
+				select {
+				case <-hup:
+					log.Info("Received SIGHUP, reloading rabbit hosts and secrets")
+					ReloadRabbitHosts()
+					secrets.reload(true)
+				case <-tick:
+					if rabbitHostsChanged() {
+						ReloadRabbitHosts()
+					}
+					secrets.reload(false)
+				}
+			}
+		}()
+	})
+}
+
+func rabbitHostsChanged() bool {
+	fi, err := os.Stat(*rabbitHostsFile)
+	if err != nil {
+		return false
+	}
+	clusters.RLock()
+	defer clusters.RUnlock()
+	return !fi.ModTime().Equal(clusters.modTime)
+}
+
+// GetRabbitClusters returns the rabbit clusters keyed by azName. The nodes of each cluster are the ones discovered
+// from rabbit, or the configured ones if they haven't been discovered recently.
+func GetRabbitClusters() (map[string]*RabbitCluster, error) {
+	cs, err := GetConfiguredRabbitClusters()
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*RabbitCluster, len(cs))
+	for az, c := range cs {
+		if nodes := getDiscoveredNodes(az); nodes != nil {
+			dc := *c
+			dc.Seeds = c.Nodes
+			dc.Nodes = nodes
+			c = &dc
+		}
+		res[az] = c
+	}
+	return res, nil
+}
+
+// GetConfiguredRabbitClusters returns the rabbit clusters in the rabbit hosts file keyed by azName, loading them the
+// first time they're needed
+func GetConfiguredRabbitClusters() (map[string]*RabbitCluster, error) {
+	if c := clusters.get(); c != nil {
+		return c, nil
+	}
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+	if c := clusters.get(); c != nil {
+		return c, nil
+	}
+	if err := reloadRabbitHosts(); err != nil {
+		return nil, err
+	}
+	return clusters.get(), nil
+}
+
+// GetRabbitCluster returns the cluster the host is a node of
+func GetRabbitCluster(host string) (*RabbitCluster, bool) {
+	cs, err := GetRabbitClusters()
+	if err != nil {
+		return nil, false
+	}
+	for _, c := range cs {
+		if c.HasNode(host) {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// Returns map of azName to rabbit hosts, for the primary clusters only
+func GetRabbitHosts() (map[string][]string, error) {
+	cs, err := GetRabbitClusters()
+	if err != nil {
+		return nil, err
+	}
+	retMap := make(map[string][]string)
+	for az, c := range cs {
+		if c.IsStandby() {
+			continue
+		}
+		retMap[az] = c.ManagementHosts()
 	}
 	return retMap, nil
 }
+
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// redactURI removes the password from any uri in s
+func redactURI(s string) string {
+	if u, err := url.Parse(s); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+			return u.String()
+		}
+	}
+	return s
+}